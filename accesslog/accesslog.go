@@ -0,0 +1,153 @@
+// Package accesslog records one JSON line per HTTP request served.
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rakhbari/gomux1/requestid"
+)
+
+// Entry is an access log line.
+type Entry struct {
+	Time       string  `json:"time"` // When the request was received, in RFC 3339 format
+	RequestId  string  `json:"request_id,omitempty"`
+	Method     string  `json:"method"`
+	Route      string  `json:"route,omitempty"` // The route template, e.g. /v1/things/{name}
+	Path       string  `json:"path"`
+	Protocol   string  `json:"protocol"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	RemoteIp   string  `json:"remote_ip"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+	TlsVersion string  `json:"tls_version,omitempty"`
+}
+
+// Logger writes access log entries to an io.Writer.
+type Logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	clock func() time.Time
+}
+
+// New returns a Logger writing to w.
+func New(w io.Writer) *Logger {
+	return &Logger{w: w, clock: time.Now}
+}
+
+// Log writes entry as a JSON line.
+func (l *Logger) Log(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(line)
+	return err
+}
+
+type contextKey struct{}
+
+// SetRoute records the route template the request in ctx was routed to. It's
+// meant to be called by a middleware of the router, which is the only place
+// the route is known.
+func SetRoute(ctx context.Context, route string) {
+	if entry, ok := ctx.Value(contextKey{}).(*Entry); ok {
+		entry.Route = route
+	}
+}
+
+// Handler returns a handler logging each request served by next. It must wrap
+// the whole router (so requests that aren't routed are logged too) and picks
+// up the request ID from the X-Request-ID response header.
+func (l *Logger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := l.clock()
+		entry := &Entry{
+			Time:      start.UTC().Format(time.RFC3339Nano),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Protocol:  r.Proto,
+			RemoteIp:  remoteIp(r.RemoteAddr),
+			UserAgent: r.UserAgent(),
+		}
+		if r.TLS != nil {
+			entry.TlsVersion = tls.VersionName(r.TLS.Version)
+		}
+		rec := &recorder{ResponseWriter: w}
+		defer func() {
+			entry.Status = rec.status
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			entry.Bytes = rec.bytes
+			entry.DurationMs = float64(l.clock().Sub(start).Microseconds()) / 1000
+			entry.RequestId = w.Header().Get(requestid.Header)
+			l.Log(entry)
+		}()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), contextKey{}, entry)))
+	})
+}
+
+// remoteIp returns the IP of a request's RemoteAddr, or RemoteAddr as is if it
+// has no port (e.g. on a Unix socket).
+func remoteIp(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// recorder records the status and size of a response.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("accesslog: ResponseWriter doesn't support hijacking")
+	}
+	rec.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
@@ -0,0 +1,76 @@
+package accesslog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rakhbari/gomux1/requestid"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := New(&buf)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	logger.clock = func() time.Time {
+		calls++
+		return now.Add(time.Duration(calls-1) * 1500 * time.Microsecond)
+	}
+	handler := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetRoute(r.Context(), "/v1/things/{name}")
+		w.Header().Set(requestid.Header, "req-1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("POST", "/v1/things/abc?x=1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("User-Agent", "test-agent")
+	req.TLS = &tls.ConnectionState{Version: tls.VersionTLS13}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry Entry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("want a single JSON line, got %q: %v", buf.String(), err)
+	}
+	want := Entry{
+		Time:       "2024-01-01T12:00:00Z",
+		RequestId:  "req-1",
+		Method:     "POST",
+		Route:      "/v1/things/{name}",
+		Path:       "/v1/things/abc",
+		Protocol:   "HTTP/1.1",
+		Status:     http.StatusCreated,
+		Bytes:      5,
+		DurationMs: 1.5,
+		RemoteIp:   "10.0.0.1",
+		UserAgent:  "test-agent",
+		TlsVersion: "TLS 1.3",
+	}
+	if entry != want {
+		t.Errorf("wrong entry:\ngot  %+v\nwant %+v", entry, want)
+	}
+}
+
+func TestHandlerDefaultStatus(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	handler := New(&buf).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/missing", nil)
+	req.RemoteAddr = "@"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry Entry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Status != http.StatusOK || entry.Route != "" || entry.RemoteIp != "@" || entry.TlsVersion != "" {
+		t.Errorf("wrong entry: %+v", entry)
+	}
+}
@@ -0,0 +1,204 @@
+package accesslog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rakhbari/gomux1/logging"
+)
+
+// backupTimeFormat is the timestamp in rotated file names, e.g.
+// access-20240101T120000.000.log for access.log.
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile is an io.WriteCloser appending to the file at Path, which it
+// rotates when it would grow past MaxSize or gets older than MaxAge. Rotated
+// files are renamed with their rotation time, gzipped if Compress is set, and
+// only the latest MaxBackups of them are kept.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64         // Bytes. 0 disables size-based rotation
+	MaxAge     time.Duration // 0 disables age-based rotation
+	MaxBackups int           // 0 keeps all rotated files
+	Compress   bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	clock    func() time.Time
+	wg       sync.WaitGroup // Compressions in flight
+	bgMu     sync.Mutex     // Serializes compressions and pruning
+}
+
+// Write appends p to the file, rotating it first if needed. p is never split
+// across files.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.size > 0 && ((f.MaxSize > 0 && f.size+int64(len(p)) > f.MaxSize) ||
+		(f.MaxAge > 0 && f.now().Sub(f.openedAt) >= f.MaxAge)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file, after waiting for rotated files to be compressed.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.wg.Wait()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) now() time.Time {
+	if f.clock != nil {
+		return f.clock()
+	}
+	return time.Now()
+}
+
+// open opens (or creates) the file for appending. An existing file's age is
+// taken from its modification time, so restarts don't reset it to zero.
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.openedAt = file, info.Size(), f.now()
+	if info.Size() > 0 {
+		f.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+// rotate renames the current file to its backup name and starts a new one.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	ext := filepath.Ext(f.Path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.Path, ext), f.now().UTC().Format(backupTimeFormat), ext)
+	if err := os.Rename(f.Path, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.bgMu.Lock()
+		defer f.bgMu.Unlock()
+		if f.Compress {
+			if err := compress(backup); err != nil {
+				slog.Error("Compressing access log failed", logging.KeyPath, backup, logging.Err(err))
+			}
+		}
+		f.prune()
+	}()
+	return nil
+}
+
+// compress gzips path to path.gz and removes path.
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune removes all but the latest MaxBackups rotated files.
+func (f *RotatingFile) prune() {
+	if f.MaxBackups <= 0 {
+		return
+	}
+	backups := f.backups()
+	if len(backups) <= f.MaxBackups {
+		return
+	}
+	for _, backup := range backups[:len(backups)-f.MaxBackups] {
+		os.Remove(backup)
+		os.Remove(backup + ".gz")
+	}
+}
+
+// backups returns the paths of the rotated files (without their .gz extension
+// if compressed), oldest first.
+func (f *RotatingFile) backups() []string {
+	ext := filepath.Ext(f.Path)
+	prefix := filepath.Base(strings.TrimSuffix(f.Path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.Path))
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil || seen[stamp] {
+			continue
+		}
+		seen[stamp] = true
+		backups = append(backups, filepath.Join(filepath.Dir(f.Path), prefix+stamp+ext))
+	}
+	sort.Strings(backups) // The timestamps sort chronologically
+	return backups
+}
@@ -0,0 +1,122 @@
+package accesslog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newTestFile returns a RotatingFile in a temp dir whose clock advances by a
+// second on every reading.
+func newTestFile(t *testing.T) *RotatingFile {
+	t.Helper()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return &RotatingFile{
+		Path: filepath.Join(t.TempDir(), "logs", "access.log"),
+		clock: func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		},
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotateBySize(t *testing.T) {
+	t.Parallel()
+	f := newTestFile(t)
+	f.MaxSize = 10
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Dir(f.Path)
+	names := listDir(t, dir)
+	if len(names) != 2 || names[0] != "access-20240101T120002.000.log" || names[1] != "access.log" {
+		t.Fatalf("wrong files: %v", names)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, names[0])); string(b) != "aaaa\nbbbb\n" {
+		t.Errorf("wrong rotated content: %q", b)
+	}
+	if b, _ := os.ReadFile(f.Path); string(b) != "cccc\n" {
+		t.Errorf("wrong current content: %q", b)
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	t.Parallel()
+	f := newTestFile(t)
+	f.MaxAge = 2 * time.Second
+	f.Write([]byte("a\n")) // opened at +1s
+	f.Write([]byte("b\n")) // +2s: 1s old
+	f.Write([]byte("c\n")) // +3s: 2s old, rotates
+	f.Close()
+
+	if b, _ := os.ReadFile(f.Path); string(b) != "c\n" {
+		t.Errorf("wrong current content: %q", b)
+	}
+	if names := listDir(t, filepath.Dir(f.Path)); len(names) != 2 {
+		t.Errorf("wrong files: %v", names)
+	}
+}
+
+func TestRotateCompressAndPrune(t *testing.T) {
+	t.Parallel()
+	f := newTestFile(t)
+	f.MaxSize = 1
+	f.MaxBackups = 2
+	f.Compress = true
+	for _, line := range []string{"1\n", "2\n", "3\n", "4\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		f.wg.Wait() // Compress in order, as if rotations were far apart
+	}
+	f.Close()
+
+	dir := filepath.Dir(f.Path)
+	names := listDir(t, dir)
+	want := []string{"access-20240101T120004.000.log.gz", "access-20240101T120006.000.log.gz", "access.log"}
+	if len(names) != len(want) {
+		t.Fatalf("wrong files: got %v want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("wrong files: got %v want %v", names, want)
+		}
+	}
+
+	gz, err := os.Open(filepath.Join(dir, names[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "3\n" {
+		t.Errorf("wrong decompressed content: %q", b)
+	}
+}
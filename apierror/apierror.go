@@ -0,0 +1,135 @@
+// Package apierror is the catalog of the errors our API returns. Each entry has
+// a stable code clients can rely on, a default message, the HTTP status it's
+// returned with and a help URL. Handlers return catalog errors (or errors
+// wrapping them) and From maps any error to one.
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HelpUrlTemplate is the help URL of catalog entries, with {code} replaced by
+// the entry's lowercase code.
+const HelpUrlTemplate = "https://github.com/rakhbari/gomux1/blob/main/docs/errors.md#{code}"
+
+// Definition is a catalog entry.
+type Definition struct {
+	Code    string `json:"code"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	HelpUrl string `json:"helpUrl"`
+}
+
+// The catalog. Codes must never be reused for a different error.
+var (
+	Internal          = Define("E0000", http.StatusInternalServerError, "Internal server error")
+	SvcAcctToken      = Define("E0001", http.StatusInternalServerError, "Problem getting the service account token")
+	NotAcceptable     = Define("E0002", http.StatusNotAcceptable, "None of the requested representations is available")
+	NotFound          = Define("E0003", http.StatusNotFound, "Resource not found")
+	Forbidden         = Define("E0004", http.StatusForbidden, "Access to the resource is forbidden")
+	Unauthorized      = Define("E0005", http.StatusUnauthorized, "Authentication is required")
+	BadRequest        = Define("E0006", http.StatusBadRequest, "Invalid request")
+	Conflict          = Define("E0007", http.StatusConflict, "Request conflicts with the current state of the resource")
+	TooManyRequests   = Define("E0008", http.StatusTooManyRequests, "Too many requests")
+	Unavailable       = Define("E0009", http.StatusServiceUnavailable, "Service unavailable")
+	Timeout           = Define("E0010", http.StatusGatewayTimeout, "Upstream request timed out")
+	KubernetesFailure = Define("E0011", http.StatusBadGateway, "Kubernetes API request failed")
+	InvalidField      = Define("E0012", http.StatusBadRequest, "Invalid field")
+	Panic             = Define("E0013", http.StatusInternalServerError, "Unexpected error while handling the request")
+	PayloadTooLarge   = Define("E0014", http.StatusRequestEntityTooLarge, "Request body too large")
+)
+
+var (
+	mu      sync.Mutex
+	catalog = map[string]*Definition{}
+)
+
+// Define adds an entry to the catalog and returns it. Embedding services can
+// define their own entries, using codes outside of E0000-E0999. It panics if
+// code is already defined.
+func Define(code string, status int, message string) *Definition {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := catalog[code]; ok {
+		panic("apierror: duplicate code " + code)
+	}
+	d := &Definition{
+		Code:    code,
+		Status:  status,
+		Message: message,
+		HelpUrl: strings.ReplaceAll(HelpUrlTemplate, "{code}", strings.ToLower(code)),
+	}
+	catalog[code] = d
+	return d
+}
+
+// Catalog returns all catalog entries, ordered by code.
+func Catalog() []Definition {
+	mu.Lock()
+	defer mu.Unlock()
+	defs := make([]Definition, 0, len(catalog))
+	for _, d := range catalog {
+		defs = append(defs, *d)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Code < defs[j].Code })
+	return defs
+}
+
+// Error makes a Definition usable as an errors.Is target.
+func (d *Definition) Error() string {
+	return d.Code + ": " + d.Message
+}
+
+// New returns an error of this kind with the given detail.
+func (d *Definition) New(format string, args ...any) *Error {
+	return &Error{Definition: d, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns an error of this kind caused by err, whose message becomes the detail.
+func (d *Definition) Wrap(err error) *Error {
+	return &Error{Definition: d, Detail: err.Error(), Err: err}
+}
+
+// Error is an occurrence of a catalog error.
+type Error struct {
+	Definition *Definition
+	Detail     string
+	Err        error // The cause, if any
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return e.Definition.Error()
+	}
+	return e.Definition.Error() + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e is an occurrence of the target Definition.
+func (e *Error) Is(target error) bool {
+	d, ok := target.(*Definition)
+	return ok && d == e.Definition
+}
+
+// From maps err to a catalog error. Kubernetes API errors anywhere in err's
+// chain take precedence, since they tell what actually went wrong (e.g. that
+// a secret doesn't exist). Otherwise the catalog error in err's chain is
+// returned, or an Internal error wrapping err if there's none.
+func From(err error) *Error {
+	if e := fromKubernetes(err); e != nil {
+		return e
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal.Wrap(err)
+}
@@ -0,0 +1,76 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFrom(t *testing.T) {
+	t.Parallel()
+	secrets := schema.GroupResource{Resource: "secrets"}
+	tests := []struct {
+		name   string
+		err    error
+		want   *Definition
+		detail string
+	}{
+		{name: "catalog error", err: BadRequest.New("namespace is required"), want: BadRequest, detail: "namespace is required"},
+		{name: "wrapped catalog error", err: fmt.Errorf("binding: %w", NotAcceptable.New("csv")), want: NotAcceptable, detail: "csv"},
+		{name: "plain error", err: errors.New("boom"), want: Internal, detail: "boom"},
+		{name: "k8s not found", err: k8serrors.NewNotFound(secrets, "user1-token"), want: NotFound, detail: `secrets "user1-token" not found`},
+		{name: "k8s forbidden", err: k8serrors.NewForbidden(secrets, "user1-token", errors.New("no RBAC")), want: Forbidden},
+		{name: "k8s unauthorized", err: k8serrors.NewUnauthorized("bad token"), want: KubernetesFailure},
+		{name: "k8s conflict", err: k8serrors.NewAlreadyExists(secrets, "user1-token"), want: Conflict},
+		{name: "k8s throttled", err: k8serrors.NewTooManyRequests("slow down", 1), want: KubernetesFailure},
+		{name: "k8s unavailable", err: k8serrors.NewServiceUnavailable("down"), want: Unavailable},
+		{name: "k8s timeout", err: k8serrors.NewTimeoutError("slow", 1), want: Timeout},
+		{name: "k8s internal", err: k8serrors.NewInternalError(errors.New("etcd")), want: KubernetesFailure},
+		{name: "k8s error wins over catalog error", err: SvcAcctToken.Wrap(fmt.Errorf("get secret: %w", k8serrors.NewNotFound(secrets, "x"))), want: NotFound},
+	}
+	for _, tt := range tests {
+		got := From(tt.err)
+		if got.Definition != tt.want {
+			t.Errorf("%s: got %v want %v", tt.name, got.Definition.Code, tt.want.Code)
+		}
+		if tt.detail != "" && got.Detail != tt.detail {
+			t.Errorf("%s: wrong detail: got %q want %q", tt.name, got.Detail, tt.detail)
+		}
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: errors.Is(%v, %v) is false", tt.name, got, tt.want.Code)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+	catalog := Catalog()
+	if len(catalog) < 12 {
+		t.Fatalf("catalog is missing entries: %d", len(catalog))
+	}
+	for i, d := range catalog {
+		if i > 0 && catalog[i-1].Code >= d.Code {
+			t.Errorf("catalog isn't ordered by code: %s before %s", catalog[i-1].Code, d.Code)
+		}
+		if d.Status < 400 || d.Message == "" {
+			t.Errorf("%s: invalid entry %+v", d.Code, d)
+		}
+	}
+	if NotFound.Status != http.StatusNotFound || NotFound.HelpUrl != "https://github.com/rakhbari/gomux1/blob/main/docs/errors.md#e0003" {
+		t.Errorf("wrong NotFound entry: %+v", NotFound)
+	}
+}
+
+func TestDefineDuplicate(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Define didn't panic on a duplicate code")
+		}
+	}()
+	Define("E0000", http.StatusTeapot, "duplicate")
+}
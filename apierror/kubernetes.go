@@ -0,0 +1,34 @@
+package apierror
+
+import (
+	"errors"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// fromKubernetes maps a Kubernetes API error in err's chain to the catalog
+// error with the matching status, or returns nil if there's none. A 401 or 429
+// is about our own credentials or request rate rather than the client's, so
+// like the errors without a match it's a KubernetesFailure.
+func fromKubernetes(err error) *Error {
+	var status k8serrors.APIStatus
+	if !errors.As(err, &status) {
+		return nil
+	}
+	d := KubernetesFailure
+	switch {
+	case k8serrors.IsNotFound(err), k8serrors.IsGone(err):
+		d = NotFound
+	case k8serrors.IsForbidden(err):
+		d = Forbidden
+	case k8serrors.IsBadRequest(err), k8serrors.IsInvalid(err):
+		d = BadRequest
+	case k8serrors.IsConflict(err), k8serrors.IsAlreadyExists(err):
+		d = Conflict
+	case k8serrors.IsServiceUnavailable(err):
+		d = Unavailable
+	case k8serrors.IsTimeout(err), k8serrors.IsServerTimeout(err):
+		d = Timeout
+	}
+	return &Error{Definition: d, Detail: status.Status().Message, Err: err}
+}
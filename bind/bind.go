@@ -0,0 +1,138 @@
+// Package bind binds HTTP requests to structs and validates them.
+//
+// The fields of the struct are bound from the request by their tags:
+//
+//	path:"name"   a path variable, e.g. {name} in a gorilla/mux route
+//	query:"name"  a query parameter
+//	form:"name"   a form field of a url-encoded or multipart POST, PUT or PATCH body
+//	json:"name"   a member of a JSON body (sent with Content-Type application/json)
+//
+// Path, query and form values override the JSON body. Fields can be strings,
+// bools, ints, uints, floats or slices of them (bound from repeated values).
+// They're then validated by their validate and pattern tags (see Validate).
+package bind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// ErrBody is returned by Request when the JSON body can't be decoded.
+var ErrBody = errors.New("invalid request body")
+
+// maxMemory is the most memory a multipart form may use before spilling to disk.
+const maxMemory = 10 << 20
+
+// Request binds r to the struct pointed to by dst and validates it. vars are
+// the request's path variables. It returns an error wrapping ErrBody if the
+// body can't be decoded or is larger than an http.MaxBytesReader allows (then
+// also wrapping the *http.MaxBytesError), or ValidationErrors if fields are invalid.
+func Request(r *http.Request, vars map[string]string, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: %T isn't a pointer to a struct", dst)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" && r.Body != nil && r.Body != http.NoBody {
+		if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+			return fmt.Errorf("%w: %w", ErrBody, err)
+		}
+	}
+	var err error
+	if mediaType == "multipart/form-data" {
+		err = r.ParseMultipartForm(maxMemory)
+	} else {
+		err = r.ParseForm()
+	}
+	// Other parse errors just leave the form empty, failing validation
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("%w: %w", ErrBody, err)
+	}
+
+	var errs ValidationErrors
+	query := r.URL.Query()
+	t := v.Elem().Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		var values []string
+		var name string
+		if name = sf.Tag.Get("path"); name != "" {
+			if value, ok := vars[name]; ok {
+				values = []string{value}
+			}
+		} else if name = sf.Tag.Get("query"); name != "" {
+			values = query[name]
+		} else if name = sf.Tag.Get("form"); name != "" {
+			values = r.PostForm[name]
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if err := setField(v.Elem().Field(i), values); err != nil {
+			errs = append(errs, FieldError{Field: name, Rule: "type", Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return Validate(dst)
+}
+
+// setField sets field from the request values bound to it.
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a non-negative integer")
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
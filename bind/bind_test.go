@@ -0,0 +1,163 @@
+package bind
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testRequest struct {
+	Name     string   `path:"name" validate:"required,dns1123label"`
+	Limit    int      `query:"limit"`
+	Verbose  bool     `query:"verbose"`
+	Tags     []string `query:"tag" pattern:"^[a-z]+$"`
+	Callback string   `form:"callback" validate:"url"`
+	Owner    string   `json:"owner" validate:"required"`
+	ignored  string
+}
+
+func TestRequest(t *testing.T) {
+	t.Parallel()
+	form := url.Values{"callback": {"https://example.com/cb"}}
+	r := httptest.NewRequest("POST", "/v1/things/abc?limit=5&verbose=true&tag=a&tag=b", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var req testRequest
+	err := Request(r, map[string]string{"name": "abc"}, &req)
+	// owner is only bound from a JSON body
+	want := ValidationErrors{{Field: "owner", Rule: "required", Message: "is required"}}
+	if !reflect.DeepEqual(err, want) {
+		t.Fatalf("wrong error: got %v want %v", err, want)
+	}
+	if req.Name != "abc" || req.Limit != 5 || !req.Verbose || !reflect.DeepEqual(req.Tags, []string{"a", "b"}) || req.Callback != "https://example.com/cb" {
+		t.Errorf("request wasn't bound: %+v", req)
+	}
+}
+
+func TestRequestJSONBody(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "/v1/things/abc?limit=5", strings.NewReader(`{"owner":"me","limit":1}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	var req struct {
+		Owner string `json:"owner" validate:"required"`
+		Limit int    `json:"limit" query:"limit"`
+	}
+	if err := Request(r, nil, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Owner != "me" || req.Limit != 5 {
+		t.Errorf("request wasn't bound, with the query overriding the body: %+v", req)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"owner":`))
+	r.Header.Set("Content-Type", "application/json")
+	if err := Request(r, nil, &req); !errors.Is(err, ErrBody) {
+		t.Errorf("malformed body: got %v want ErrBody", err)
+	}
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	t.Parallel()
+	var req struct {
+		Owner string `json:"owner" form:"owner"`
+	}
+	for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded"} {
+		body := `{"owner":"` + strings.Repeat("a", 100) + `"}`
+		if contentType != "application/json" {
+			body = "owner=" + strings.Repeat("a", 100)
+		}
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 50)
+		err := Request(r, nil, &req)
+		var tooLarge *http.MaxBytesError
+		if !errors.Is(err, ErrBody) || !errors.As(err, &tooLarge) || tooLarge.Limit != 50 {
+			t.Errorf("%s: got %v want ErrBody wrapping a *http.MaxBytesError", contentType, err)
+		}
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		url    string
+		vars   map[string]string
+		fields map[string]string // field -> rule
+	}{
+		{name: "missing path var", url: "/", fields: map[string]string{"name": "required", "owner": "required"}},
+		{name: "invalid name", url: "/", vars: map[string]string{"name": "Not_A_Label"}, fields: map[string]string{"name": "dns1123label", "owner": "required"}},
+		{name: "type error", url: "/?limit=many&verbose=maybe", vars: map[string]string{"name": "abc"}, fields: map[string]string{"limit": "type", "verbose": "type"}},
+		{name: "pattern", url: "/?tag=ok&tag=NOT", vars: map[string]string{"name": "abc"}, fields: map[string]string{"tag": "pattern", "owner": "required"}},
+	}
+	for _, tt := range tests {
+		var req testRequest
+		err := Request(httptest.NewRequest("GET", tt.url, nil), tt.vars, &req)
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: got %v want ValidationErrors", tt.name, err)
+			continue
+		}
+		got := map[string]string{}
+		for _, fe := range errs {
+			got[fe.Field] = fe.Rule
+		}
+		if !reflect.DeepEqual(got, tt.fields) {
+			t.Errorf("%s: wrong errors: got %v want %v", tt.name, got, tt.fields)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{value: "https://argo.example.com:9443", valid: true},
+		{value: "http://localhost/path?q=1", valid: true},
+		{value: "ftp://example.com", valid: false},
+		{value: "/relative", valid: false},
+		{value: "not a url", valid: false},
+	}
+	for _, tt := range tests {
+		req := struct {
+			Url string `form:"url" validate:"url"`
+		}{Url: tt.value}
+		if err := Validate(&req); (err == nil) != tt.valid {
+			t.Errorf("%q: got %v, want valid=%v", tt.value, err, tt.valid)
+		}
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	t.Parallel()
+	if err := CheckTags(testRequest{}); err != nil {
+		t.Errorf("valid tags: got %v", err)
+	}
+	unknownRule := struct {
+		Name string `validate:"required,dns1123"`
+	}{}
+	if err := CheckTags(&unknownRule); err == nil || !strings.Contains(err.Error(), `unknown validation rule "dns1123"`) {
+		t.Errorf("unknown rule: got %v", err)
+	}
+	badPattern := struct {
+		Name string `pattern:"^[a-z+$"`
+	}{}
+	if err := CheckTags(&badPattern); err == nil || !strings.Contains(err.Error(), "invalid pattern on field Name") {
+		t.Errorf("invalid pattern: got %v", err)
+	}
+}
+
+func TestRequestNotAStruct(t *testing.T) {
+	t.Parallel()
+	var s string
+	if err := Request(httptest.NewRequest(http.MethodGet, "/", nil), nil, &s); err == nil {
+		t.Errorf("binding to a *string didn't fail")
+	}
+}
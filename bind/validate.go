@@ -0,0 +1,199 @@
+package bind
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// FieldError describes why a field is invalid.
+type FieldError struct {
+	Field   string // The field's request name, from its path, query, form or json tag
+	Rule    string // The rule it broke, e.g. "required"
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists the invalid fields of a request.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Error())
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// rules are the validations that can be listed in a validate tag, e.g.
+// validate:"required,dns1123label". They're only applied to non-empty values.
+var rules = map[string]func(value string) string{
+	"dns1123label": func(value string) string {
+		return strings.Join(validation.IsDNS1123Label(value), "; ")
+	},
+	"dns1123subdomain": func(value string) string {
+		return strings.Join(validation.IsDNS1123Subdomain(value), "; ")
+	},
+	"url": func(value string) string {
+		u, err := url.ParseRequestURI(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+		return ""
+	},
+}
+
+var patterns sync.Map // pattern -> *regexp.Regexp
+
+// CheckTags checks the validate and pattern tags of the struct v (or the one
+// it points to), returning an error for an unknown rule or a pattern that
+// isn't a valid regular expression. Validate panics on them instead, so
+// handlers should call it when they're built, to fail at startup.
+func CheckTags(v any) error {
+	t := reflect.Indirect(reflect.ValueOf(v)).Type()
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("bind: %T isn't a struct", v)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				rule = strings.TrimSpace(rule)
+				if _, ok := rules[rule]; !ok && rule != "required" && rule != "" {
+					return fmt.Errorf("bind: unknown validation rule %q on field %s of %s", rule, sf.Name, t)
+				}
+			}
+		}
+		if pattern := sf.Tag.Get("pattern"); pattern != "" {
+			if _, err := compilePattern(pattern); err != nil {
+				return fmt.Errorf("bind: invalid pattern on field %s of %s: %w", sf.Name, t, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compilePattern returns the compiled regular expression of a pattern tag.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// Validate validates the fields of the struct pointed to by v by their tags:
+//
+//	validate:"required"          the field can't be empty (its zero value)
+//	validate:"dns1123label"      a Kubernetes name such as a namespace
+//	validate:"dns1123subdomain"  a Kubernetes name such as a service account
+//	validate:"url"               an absolute http or https URL
+//	pattern:"^[a-z]+$"           a regular expression the value must match
+//
+// Rules are comma-separated, e.g. validate:"required,url". Rules other than
+// required only apply to non-empty string fields (or their elements, for
+// slices of strings). It returns ValidationErrors if fields are invalid, and
+// panics on an unknown rule or an invalid pattern (see CheckTags).
+func Validate(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	t := rv.Type()
+	var errs ValidationErrors
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, pattern := sf.Tag.Get("validate"), sf.Tag.Get("pattern")
+		if !sf.IsExported() || (tag == "" && pattern == "") {
+			continue
+		}
+		name := fieldName(sf)
+		field := rv.Field(i)
+		var fieldRules []string
+		if tag != "" {
+			fieldRules = strings.Split(tag, ",")
+		}
+		if field.IsZero() {
+			for _, rule := range fieldRules {
+				if strings.TrimSpace(rule) == "required" {
+					errs = append(errs, FieldError{Field: name, Rule: "required", Message: "is required"})
+				}
+			}
+			continue
+		}
+		for _, value := range stringValues(field) {
+			if fe := validateValue(name, value, fieldRules, pattern); fe != nil {
+				errs = append(errs, *fe)
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateValue returns the first rule value breaks, or nil.
+func validateValue(name string, value string, fieldRules []string, pattern string) *FieldError {
+	for _, rule := range fieldRules {
+		rule = strings.TrimSpace(rule)
+		if rule == "required" || rule == "" {
+			continue
+		}
+		check, ok := rules[rule]
+		if !ok {
+			panic(fmt.Sprintf("bind: unknown validation rule %q on field %s", rule, name))
+		}
+		if msg := check(value); msg != "" {
+			return &FieldError{Field: name, Rule: rule, Message: msg}
+		}
+	}
+	if pattern != "" {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			panic(fmt.Sprintf("bind: invalid pattern on field %s: %v", name, err))
+		}
+		if !re.MatchString(value) {
+			return &FieldError{Field: name, Rule: "pattern", Message: "must match " + pattern}
+		}
+	}
+	return nil
+}
+
+// stringValues returns the string values of a string or []string field.
+func stringValues(field reflect.Value) []string {
+	switch {
+	case field.Kind() == reflect.String:
+		return []string{field.String()}
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		values := make([]string, field.Len())
+		for i := range values {
+			values[i] = field.Index(i).String()
+		}
+		return values
+	}
+	return nil
+}
+
+// fieldName returns the name a field is bound by in requests.
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"path", "query", "form", "json"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
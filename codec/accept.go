@@ -0,0 +1,74 @@
+package codec
+
+import (
+	"strconv"
+	"strings"
+)
+
+// mediaRange is one of the media ranges listed in an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an Accept header (RFC 9110 section 12.5.1). Malformed
+// ranges are skipped; media type parameters other than q are ignored.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.TrimSpace(params[0]), "/")
+		if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+			continue
+		}
+		mr := mediaRange{typ: strings.ToLower(typ), subtype: strings.ToLower(subtype), q: 1}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				mr.q = q
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// quality returns the q-value ranges give mediaType: that of the most specific
+// range matching it, or 0 if none does.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		var s int
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
+
+// Lists reports whether accept names mediaType itself (not just through a
+// wildcard) with a non-zero q-value.
+func Lists(accept string, mediaType string) bool {
+	typ, subtype, _ := strings.Cut(strings.ToLower(mediaType), "/")
+	for _, mr := range parseAccept(accept) {
+		if mr.typ == typ && mr.subtype == subtype {
+			return mr.q > 0
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+// Package codec encodes API responses in the representation a client asks for
+// via content negotiation.
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotAcceptable is returned by Registry.Negotiate when none of the
+// registered encoders produces a representation the client accepts.
+var ErrNotAcceptable = errors.New("none of the requested representations is available")
+
+// Encoder encodes values in one representation.
+type Encoder struct {
+	// Format is the short name a client can ask for with ?format=, e.g. "json".
+	Format string
+	// MediaTypes are the media types the encoder is selected by. The first one
+	// is sent as the response Content-Type.
+	MediaTypes []string
+	// Marshal encodes v, honouring its `json` struct tags.
+	Marshal func(v any) ([]byte, error)
+}
+
+// ContentType returns the Content-Type of the encoder's output.
+func (e Encoder) ContentType() string {
+	return e.MediaTypes[0]
+}
+
+// Registry is an ordered set of encoders. The first one is the default, used
+// when the client doesn't express a preference.
+type Registry struct {
+	encoders []Encoder
+}
+
+// NewRegistry returns a registry of the given encoders.
+func NewRegistry(encoders ...Encoder) *Registry {
+	r := &Registry{}
+	for _, e := range encoders {
+		r.Register(e)
+	}
+	return r
+}
+
+// DefaultRegistry returns a registry of the built-in encoders, with JSON as the default.
+func DefaultRegistry() *Registry {
+	return NewRegistry(JSON, YAML, XML, MessagePack)
+}
+
+// Register adds e to the registry, replacing any encoder of the same format.
+func (r *Registry) Register(e Encoder) {
+	for i := range r.encoders {
+		if strings.EqualFold(r.encoders[i].Format, e.Format) {
+			r.encoders[i] = e
+			return
+		}
+	}
+	r.encoders = append(r.encoders, e)
+}
+
+// Default returns the registry's default encoder.
+func (r *Registry) Default() Encoder {
+	return r.encoders[0]
+}
+
+// ContentTypes returns the Content-Types the registry can produce.
+func (r *Registry) ContentTypes() []string {
+	types := make([]string, 0, len(r.encoders))
+	for _, e := range r.encoders {
+		types = append(types, e.ContentType())
+	}
+	return types
+}
+
+// Negotiate picks the encoder for a request. An explicit format (the ?format=
+// query parameter) wins over the Accept header. Otherwise the encoder with the
+// highest q-value in accept is picked, ties going to the earlier registered
+// one. An empty accept selects the default encoder.
+func (r *Registry) Negotiate(accept string, format string) (Encoder, error) {
+	if format != "" {
+		for _, e := range r.encoders {
+			if strings.EqualFold(e.Format, format) {
+				return e, nil
+			}
+		}
+		return Encoder{}, fmt.Errorf("%w: unknown format %q", ErrNotAcceptable, format)
+	}
+
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return r.Default(), nil
+	}
+	var best Encoder
+	var bestQ float64
+	for _, e := range r.encoders {
+		for _, mediaType := range e.MediaTypes {
+			if q := quality(ranges, mediaType); q > bestQ {
+				best, bestQ = e, q
+			}
+		}
+	}
+	if bestQ == 0 {
+		return Encoder{}, fmt.Errorf("%w: %s", ErrNotAcceptable, accept)
+	}
+	return best, nil
+}
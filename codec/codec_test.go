@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	registry := DefaultRegistry()
+	tests := []struct {
+		name    string
+		accept  string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{name: "no preference", accept: "", want: "json"},
+		{name: "any", accept: "*/*", want: "json"},
+		{name: "exact", accept: "application/yaml", want: "yaml"},
+		{name: "alias", accept: "text/xml", want: "xml"},
+		{name: "msgpack", accept: "application/x-msgpack", want: "msgpack"},
+		{name: "q-values", accept: "application/json;q=0.5, application/xml;q=0.9", want: "xml"},
+		{name: "wildcard fallback", accept: "text/html, */*;q=0.1", want: "json"},
+		{name: "subtype wildcard", accept: "text/*", want: "yaml"},
+		{name: "most specific range wins", accept: "application/*;q=0.8, application/json;q=0", want: "yaml"},
+		{name: "tie goes to the default", accept: "application/yaml, application/json", want: "json"},
+		{name: "case and params", accept: "Application/YAML; charset=utf-8", want: "yaml"},
+		{name: "format overrides accept", accept: "application/json", format: "msgpack", want: "msgpack"},
+		{name: "format is case insensitive", format: "YAML", want: "yaml"},
+		{name: "unsupported", accept: "text/html", wantErr: true},
+		{name: "all refused", accept: "*/*;q=0", wantErr: true},
+		{name: "unknown format", format: "csv", wantErr: true},
+	}
+	for _, tt := range tests {
+		enc, err := registry.Negotiate(tt.accept, tt.format)
+		if tt.wantErr {
+			if !errors.Is(err, ErrNotAcceptable) {
+				t.Errorf("%s: got %v, %v want ErrNotAcceptable", tt.name, enc.Format, err)
+			}
+			continue
+		}
+		if err != nil || enc.Format != tt.want {
+			t.Errorf("%s: got %v, %v want %v", tt.name, enc.Format, err, tt.want)
+		}
+	}
+}
+
+func TestRegisterReplacesFormat(t *testing.T) {
+	t.Parallel()
+	registry := DefaultRegistry()
+	custom := Encoder{Format: "json", MediaTypes: []string{"application/json"}, Marshal: func(any) ([]byte, error) { return []byte("custom"), nil }}
+	registry.Register(custom)
+	enc, err := registry.Negotiate("application/json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := enc.Marshal(nil); string(b) != "custom" {
+		t.Errorf("json encoder wasn't replaced: got %s", b)
+	}
+	if got := len(registry.ContentTypes()); got != 4 {
+		t.Errorf("wrong number of encoders: got %d want 4", got)
+	}
+}
+
+type testPayload struct {
+	Name  string         `json:"name"`
+	Count int            `json:"count"`
+	Ratio float64        `json:"ratio"`
+	Tags  []string       `json:"tags"`
+	Extra map[string]any `json:"extra"`
+}
+
+func TestMarshalXML(t *testing.T) {
+	t.Parallel()
+	got, err := XML.Marshal(testPayload{
+		Name:  "a<b",
+		Count: 2,
+		Ratio: 0.5,
+		Tags:  []string{"x", "y"},
+		Extra: map[string]any{"1st": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<response><name>a&lt;b</name><count>2</count><ratio>0.5</ratio>` +
+		`<tags><item>x</item><item>y</item></tags>` +
+		`<extra><entry key="1st">true</entry></extra></response>`
+	if string(got) != want {
+		t.Errorf("wrong XML:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestMarshalMessagePack(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		value any
+		want  []byte
+	}{
+		{name: "nil", value: nil, want: []byte{0xc0}},
+		{name: "bool", value: true, want: []byte{0xc3}},
+		{name: "fixint", value: 7, want: []byte{0x07}},
+		{name: "negative fixint", value: -1, want: []byte{0xff}},
+		{name: "uint8", value: 200, want: []byte{0xcc, 0xc8}},
+		{name: "uint16", value: 1000, want: []byte{0xcd, 0x03, 0xe8}},
+		{name: "int8", value: -100, want: []byte{0xd0, 0x9c}},
+		{name: "int32", value: -100000, want: []byte{0xd2, 0xff, 0xfe, 0x79, 0x60}},
+		{name: "float64", value: 1.5, want: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{name: "fixstr", value: "hi", want: []byte{0xa2, 'h', 'i'}},
+		{name: "str8", value: string(bytes.Repeat([]byte{'a'}, 40)), want: append([]byte{0xd9, 40}, bytes.Repeat([]byte{'a'}, 40)...)},
+		{name: "fixarray", value: []int{1, 2}, want: []byte{0x92, 0x01, 0x02}},
+		{
+			name: "fixmap keeps field order",
+			value: struct {
+				B int    `json:"b"`
+				A string `json:"a"`
+			}{B: 1, A: "x"},
+			want: []byte{0x82, 0xa1, 'b', 0x01, 0xa1, 'a', 0xa1, 'x'},
+		},
+	}
+	for _, tt := range tests {
+		got, err := MessagePack.Marshal(tt.value)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got % x want % x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLists(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "application/problem+json", want: true},
+		{accept: "application/json, Application/Problem+JSON;q=0.5", want: true},
+		{accept: "application/problem+json;q=0", want: false},
+		{accept: "application/*, */*", want: false},
+		{accept: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := Lists(tt.accept, "application/problem+json"); got != tt.want {
+			t.Errorf("Lists(%q): got %v want %v", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package codec
+
+import (
+	"encoding/json"
+
+	"sigs.k8s.io/yaml"
+)
+
+// The built-in encoders.
+var (
+	JSON = Encoder{
+		Format:     "json",
+		MediaTypes: []string{"application/json"},
+		Marshal:    json.Marshal,
+	}
+	YAML = Encoder{
+		Format:     "yaml",
+		MediaTypes: []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		Marshal:    yaml.Marshal,
+	}
+	XML = Encoder{
+		Format:     "xml",
+		MediaTypes: []string{"application/xml", "text/xml"},
+		Marshal:    marshalXML,
+	}
+	MessagePack = Encoder{
+		Format:     "msgpack",
+		MediaTypes: []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		Marshal:    marshalMessagePack,
+	}
+)
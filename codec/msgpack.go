@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// marshalMessagePack encodes v as MessagePack (https://msgpack.org), using the
+// most compact representation of each value.
+func marshalMessagePack(v any) ([]byte, error) {
+	tree, err := toTree(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encodeMessagePack(&buf, tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeMessagePack(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case string:
+		writeLength(buf, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case json.Number:
+		return encodeNumber(buf, v)
+	case []any:
+		writeLength(buf, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := encodeMessagePack(buf, item); err != nil {
+				return err
+			}
+		}
+	case object:
+		writeLength(buf, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		for _, f := range v {
+			if err := encodeMessagePack(buf, f.key); err != nil {
+				return err
+			}
+			if err := encodeMessagePack(buf, f.value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported value type %T", value)
+	}
+	return nil
+}
+
+// writeLength writes the header of a string, array or map of length n: the
+// fix type (fix|n) if n < fixMax, else the 8 (if non-zero), 16 or 32 bit type.
+func writeLength(buf *bytes.Buffer, n int, fix byte, fixMax int, type8, type16, type32 byte) {
+	switch {
+	case n < fixMax:
+		buf.WriteByte(fix | byte(n))
+	case type8 != 0 && n <= math.MaxUint8:
+		buf.Write([]byte{type8, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(type16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(type32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func encodeNumber(buf *bytes.Buffer, n json.Number) error {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		switch {
+		case i >= 0:
+			encodeUint(buf, uint64(i))
+		case i >= -32:
+			buf.WriteByte(byte(int8(i))) // negative fixint
+		case i >= math.MinInt8:
+			buf.Write([]byte{0xd0, byte(int8(i))})
+		case i >= math.MinInt16:
+			buf.WriteByte(0xd1)
+			binary.Write(buf, binary.BigEndian, int16(i))
+		case i >= math.MinInt32:
+			buf.WriteByte(0xd2)
+			binary.Write(buf, binary.BigEndian, int32(i))
+		default:
+			buf.WriteByte(0xd3)
+			binary.Write(buf, binary.BigEndian, i)
+		}
+		return nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		encodeUint(buf, u)
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	buf.WriteByte(0xcb)
+	binary.Write(buf, binary.BigEndian, f)
+	return nil
+}
+
+func encodeUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u <= 0x7f:
+		buf.WriteByte(byte(u)) // positive fixint
+	case u <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(u)})
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(u))
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(u))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, u)
+	}
+}
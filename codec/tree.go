@@ -0,0 +1,65 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// field is a member of an object, in the order it was encoded.
+type field struct {
+	key   string
+	value any
+}
+
+// object is a JSON object whose members keep their order.
+type object []field
+
+// toTree converts v to the generic tree of its JSON encoding, so encoders for
+// other representations use the same `json` field names and order. The tree
+// is made of object, []any, string, json.Number, bool and nil values.
+func toTree(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return readValue(dec)
+}
+
+func readValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{key: key.(string), value: value})
+		}
+		_, err = dec.Token() // '}'
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			value, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err = dec.Token() // ']'
+		return arr, err
+	default:
+		return tok, nil
+	}
+}
@@ -0,0 +1,86 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// marshalXML encodes v as a <response> document with an element per JSON
+// object member. Array elements are encoded as <item> elements, and object
+// members whose names aren't valid XML names as <entry key="name"> elements.
+func marshalXML(v any) ([]byte, error) {
+	tree, err := toTree(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXML(enc, xml.StartElement{Name: xml.Name{Local: "response"}}, tree); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXML(enc *xml.Encoder, start xml.StartElement, value any) error {
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case object:
+		for _, f := range v {
+			if err := encodeXML(enc, xmlElement(f.key), f.value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := encodeXML(enc, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlElement returns the element for an object member named key.
+func xmlElement(key string) xml.StartElement {
+	if validXMLName(key) {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{
+		Name: xml.Name{Local: "entry"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+	}
+}
+
+// validXMLName reports whether name can be used as an element name as is. It's
+// stricter than the XML spec: only ASCII letters, digits, '_', '-' and '.' are
+// allowed, and names can't start with a digit, '-', '.' or "xml".
+func validXMLName(name string) bool {
+	if name == "" || (len(name) >= 3 && strings.EqualFold(name[:3], "xml")) {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case (c >= '0' && c <= '9') || c == '-' || c == '.':
+			if i == 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -5,14 +5,97 @@ type Config struct {
         Host           string   `env:"SERVER_HOST, default=0.0.0.0"`
         HttpPort       int      `env:"SERVER_HTTP_PORT, default=8080"`
         HttpsPort      int      `env:"SERVER_HTTPS_PORT, default=8443"`
+        HttpListen     string   `env:"SERVER_HTTP_LISTEN"`  // Overrides Host/HttpPort, e.g. unix:///run/gomux1.sock or systemd:
+        HttpsListen    string   `env:"SERVER_HTTPS_LISTEN"` // Overrides Host/HttpsPort
+        Listeners      string   `env:"SERVER_LISTENERS"`    // JSON array of ListenerConfig. Overrides all of the above
         TlsCertPath    string   `env:"SERVER_TLS_CERT_PATH"`
         TlsKeyPath     string   `env:"SERVER_TLS_KEY_PATH"`
         TlsCaPaths     []string `env:"SERVER_TLS_CA_PATHS"`
+        HttpsRedirect  bool     `env:"SERVER_HTTPS_REDIRECT, default=false"` // HTTP listener only serves /health and redirects the rest to HTTPS
         WriteTimeout   int      `env:"SERVER_WRITE_TIMEOUT, default=15"`
         ReadTimeout    int      `env:"SERVER_READ_TIMEOUT, default=15"`
         IdleTimeout    int      `env:"SERVER_IDLE_TIMEOUT, default=60"`
-        TempDir        string   `env:"SERVER_TEMP_DIR, default=."`
-        KubeconfigPath string   `env:"KUBECONFIG_PATH, default=~/.kube/config"`
+        PreStopDelay   int      `env:"SERVER_PRESTOP_DELAY, default=5"`
+        UpgradeBinary  string   `env:"SERVER_UPGRADE_BINARY"`
+        UpgradeTimeout int      `env:"SERVER_UPGRADE_TIMEOUT, default=30"`
+        KubeconfigPath string   `env:"KUBECONFIG_PATH, default=~/.kube/config"` // The in-cluster config is used if it's the default and there's no file there
+        MaxBodyBytes   int      `env:"SERVER_MAX_BODY_BYTES, default=1048576"` // Largest request body the typed handlers read. 0 disables
+    }
+
+    Http2 struct {
+        H2c                  bool   `env:"HTTP2_H2C, default=false"` // Serve h2c (prior knowledge and Upgrade) on the HTTP listener
+        MaxConcurrentStreams uint32 `env:"HTTP2_MAX_CONCURRENT_STREAMS, default=250"`
+        MaxReadFrameSize     uint32 `env:"HTTP2_MAX_READ_FRAME_SIZE, default=1048576"`
+    }
+
+    Hsts struct {
+        MaxAge            int  `env:"HSTS_MAX_AGE, default=0"` // Seconds. 0 disables the Strict-Transport-Security header
+        IncludeSubDomains bool `env:"HSTS_INCLUDE_SUBDOMAINS, default=false"`
+        Preload           bool `env:"HSTS_PRELOAD, default=false"`
+    }
+
+    Log struct {
+        Level  string `env:"LOG_LEVEL, default=info"`  // debug, info, warn or error
+        Format string `env:"LOG_FORMAT, default=json"` // json or text
+    }
+
+    AccessLog struct {
+        Enabled     bool   `env:"ACCESS_LOG_ENABLED, default=true"`
+        Path        string `env:"ACCESS_LOG_PATH"` // Defaults to stdout
+        MaxSizeMb   int    `env:"ACCESS_LOG_MAX_SIZE_MB, default=100"`   // Rotate the file when it reaches this size. 0 disables
+        MaxAgeHours int    `env:"ACCESS_LOG_MAX_AGE_HOURS, default=24"`  // Rotate the file when it's this old. 0 disables
+        MaxBackups  int    `env:"ACCESS_LOG_MAX_BACKUPS, default=7"`     // Rotated files to keep. 0 keeps all
+        Compress    bool   `env:"ACCESS_LOG_COMPRESS, default=true"`     // Gzip rotated files
+    }
+
+    Tracing struct {
+        Exporter     string   `env:"TRACING_EXPORTER"` // jsonl or otlp. Spans are propagated but not exported if unset
+        Path         string   `env:"TRACING_PATH"`     // File the jsonl exporter appends to. Defaults to stdout
+        OtlpEndpoint string   `env:"TRACING_OTLP_ENDPOINT, default=http://localhost:4318/v1/traces"`
+        OtlpHeaders  []string `env:"TRACING_OTLP_HEADERS"` // key=value headers sent to the OTLP collector, e.g. for authentication. Redacted in logs
+        ServiceName  string   `env:"TRACING_SERVICE_NAME, default=gomux1"`
+    }
+
+    RateLimit struct {
+        Enabled        bool   `env:"RATE_LIMIT_ENABLED, default=true"`
+        Limits         string `env:"RATE_LIMITS"`                               // JSON array of RateLimitConfig. Defaults to DefaultRateLimits
+        IdleTimeout    int    `env:"RATE_LIMIT_IDLE_TIMEOUT, default=600"`      // Seconds after which an idle client's bucket is forgotten
+        MaxClients     int    `env:"RATE_LIMIT_MAX_CLIENTS, default=10000"`     // Buckets kept per limit, past which the least recently used is forgotten. 0 disables
+        TrustForwarded bool   `env:"RATE_LIMIT_TRUST_FORWARDED, default=false"` // Tell clients apart by the first X-Forwarded-For address. Only enable behind a proxy setting it
+    }
+
+    LoadShed struct {
+        Enabled         bool     `env:"LOAD_SHED_ENABLED, default=true"`
+        Limiter         string   `env:"LOAD_SHED_LIMITER, default=static"`                                                              // static, aimd or gradient
+        Limit           int      `env:"LOAD_SHED_LIMIT, default=200"`                                                                   // Requests served at once. Initial limit of the adaptive limiters
+        MinLimit        int      `env:"LOAD_SHED_MIN_LIMIT, default=10"`                                                                // Adaptive limiters only
+        MaxLimit        int      `env:"LOAD_SHED_MAX_LIMIT, default=1000"`                                                              // Adaptive limiters only
+        TargetLatencyMs int      `env:"LOAD_SHED_TARGET_LATENCY_MS, default=500"`                                                       // aimd: latency above which the limit is decreased
+        QueueSize       int      `env:"LOAD_SHED_QUEUE_SIZE, default=100"`                                                              // Normal priority requests that can wait for a slot
+        QueueTimeoutMs  int      `env:"LOAD_SHED_QUEUE_TIMEOUT_MS, default=100"`                                                        // How long they wait before being shed
+        CriticalPaths   []string `env:"LOAD_SHED_CRITICAL_PATHS, default=[/health,/livez,/readyz,/startupz,/version,/metrics,/debug/]"` // Never shed. Paths ending with / are prefixes
+        NormalPaths     []string `env:"LOAD_SHED_NORMAL_PATHS, default=[/v1/]"`                                                         // Queued, then shed. Other paths are shed first
+    }
+
+    Health struct {
+        Interval      int      `env:"HEALTH_INTERVAL, default=10"`        // Seconds between runs of the built-in checks
+        Timeout       int      `env:"HEALTH_TIMEOUT, default=5"`          // Seconds a run of a built-in check may take
+        Kubernetes    bool     `env:"HEALTH_KUBERNETES, default=false"`   // Check the Kubernetes API used by /v1/bearer-token is reachable and accepts our credentials
+        TlsExpiryDays int      `env:"HEALTH_TLS_EXPIRY_DAYS, default=14"` // Warn when a TLS listener's cert expires within this many days. 0 disables
+        TcpAddrs      []string `env:"HEALTH_TCP_ADDRS"`                   // host:port of TCP dependencies
+        RedisAddrs    []string `env:"HEALTH_REDIS_ADDRS"`                 // host:port of Redis servers to PING
+        RedisPassword string   `env:"HEALTH_REDIS_PASSWORD"`              // Redacted in logs
+        SqlDriver     string   `env:"HEALTH_SQL_DRIVER"`                  // database/sql driver of the database to SELECT 1 from, which must be linked into the binary
+        SqlDsn        string   `env:"HEALTH_SQL_DSN"`                     // Redacted in logs
+    }
+
+    Debug struct {
+        Token string `env:"DEBUG_TOKEN"` // Bearer token the debug routes require. Needed to mount them on a listener serving the api or static routes. Redacted in logs
+    }
+
+    Errors struct {
+        ProblemDetails bool `env:"ERRORS_PROBLEM_DETAILS, default=false"` // Render JSON error responses as RFC 7807 application/problem+json
+        Debug          bool `env:"ERRORS_DEBUG, default=false"`           // Include panic stack traces in error details. Never enable in production
     }
 
     WebApp struct {
@@ -0,0 +1,144 @@
+package config
+
+import (
+    "encoding/json"
+    "fmt"
+)
+
+// Route groups that can be mounted on a listener
+const (
+    RoutesApi    = "api"    // /v1/*
+    RoutesOps    = "ops"    // /health, /livez, /readyz, /startupz, /version, /metrics
+    RoutesStatic = "static" // /app/, /styles/, /images/, /scripts/
+    RoutesDebug  = "debug"  // /debug/pprof/, /debug/runtime, /debug/goroutines. Not mounted by default
+)
+
+// DefaultRoutes are the route groups mounted on a listener that doesn't list any.
+var DefaultRoutes = []string{RoutesApi, RoutesOps, RoutesStatic}
+
+// Listener protocols
+const (
+    ProtocolHttp1 = "http/1.1" // HTTP/1.1 only
+    ProtocolH2    = "h2"       // HTTP/2 (over TLS), falling back to HTTP/1.1
+    ProtocolH2c   = "h2c"      // HTTP/2 cleartext (prior knowledge or Upgrade), falling back to HTTP/1.1
+)
+
+// ListenerConfig describes one of the app's listeners and the routes it serves.
+type ListenerConfig struct {
+    Name          string   `json:"name"`
+    Addr          string   `json:"addr"`          // host:port, unix:///path/to.sock or systemd:[name]
+    Tls           bool     `json:"tls"`           // Serve TLS, by default with the SERVER_TLS_* cert
+    TlsCertPath   string   `json:"certPath"`      // Overrides SERVER_TLS_CERT_PATH
+    TlsKeyPath    string   `json:"keyPath"`       // Overrides SERVER_TLS_KEY_PATH
+    TlsCaPaths    []string `json:"caPaths"`       // Overrides SERVER_TLS_CA_PATHS
+    Protocol      string   `json:"protocol"`      // Defaults to h2 for TLS listeners and http/1.1 (or h2c if HTTP2_H2C is set) otherwise
+    Routes        []string `json:"routes"`        // Route groups to mount. Defaults to DefaultRoutes
+    HttpsRedirect bool     `json:"httpsRedirect"` // Only serve the health probes and 308-redirect the rest to the port of the TLS listener
+}
+
+// ListenerConfigs returns the app's listeners. They're read from the JSON array
+// in SERVER_LISTENERS if set, e.g.:
+//
+//  [{"name":"public","addr":":8443","tls":true,"routes":["api","static"]},
+//   {"name":"admin","addr":"127.0.0.1:9090","routes":["ops"]}]
+//
+// Otherwise they're the HTTP listener on SERVER_HTTP_LISTEN (or SERVER_HOST:SERVER_HTTP_PORT)
+// plus, if SERVER_TLS_CERT_PATH is set, the TLS listener on SERVER_HTTPS_LISTEN
+// (or SERVER_HOST:SERVER_HTTPS_PORT), both serving all routes unless
+// SERVER_HTTPS_REDIRECT turns the HTTP listener into a redirect to the TLS one.
+func (c *Config) ListenerConfigs() ([]ListenerConfig, error) {
+    var listeners []ListenerConfig
+    if c.Server.Listeners != "" {
+        if err := json.Unmarshal([]byte(c.Server.Listeners), &listeners); err != nil {
+            return nil, fmt.Errorf("invalid SERVER_LISTENERS: %w", err)
+        }
+    } else {
+        httpAddr := fmt.Sprintf("%s:%d", c.Server.Host, c.Server.HttpPort)
+        if c.Server.HttpListen != "" {
+            httpAddr = c.Server.HttpListen
+        }
+        listeners = append(listeners, ListenerConfig{Name: "HTTP", Addr: httpAddr})
+        if c.Http2.H2c {
+            listeners[0].Protocol = ProtocolH2c
+        }
+
+        // If TlsCertPath is passed in, start a TLS listener also
+        if len(c.Server.TlsCertPath) > 0 {
+            httpsAddr := fmt.Sprintf("%s:%d", c.Server.Host, c.Server.HttpsPort)
+            if c.Server.HttpsListen != "" {
+                httpsAddr = c.Server.HttpsListen
+            }
+            listeners = append(listeners, ListenerConfig{Name: "TLS", Addr: httpsAddr, Tls: true})
+            listeners[0].HttpsRedirect = c.Server.HttpsRedirect
+        }
+    }
+
+    names := map[string]bool{}
+    for i := range listeners {
+        l := &listeners[i]
+        if l.Name == "" || l.Addr == "" {
+            return nil, fmt.Errorf("listener #%d: name and addr are required", i+1)
+        }
+        if names[l.Name] {
+            return nil, fmt.Errorf("listener %s: duplicate name", l.Name)
+        }
+        names[l.Name] = true
+
+        if l.Tls {
+            if l.TlsCertPath == "" {
+                l.TlsCertPath = c.Server.TlsCertPath
+            }
+            if l.TlsKeyPath == "" {
+                l.TlsKeyPath = c.Server.TlsKeyPath
+            }
+            if l.TlsCaPaths == nil {
+                l.TlsCaPaths = c.Server.TlsCaPaths
+            }
+            if l.TlsCertPath == "" {
+                return nil, fmt.Errorf("listener %s: tls is enabled but no cert path is set", l.Name)
+            }
+        }
+
+        switch l.Protocol {
+        case "":
+            l.Protocol = ProtocolHttp1
+            if l.Tls {
+                l.Protocol = ProtocolH2
+            }
+        case ProtocolHttp1:
+        case ProtocolH2:
+            if !l.Tls {
+                return nil, fmt.Errorf("listener %s: protocol %s requires tls", l.Name, l.Protocol)
+            }
+        case ProtocolH2c:
+            if l.Tls {
+                return nil, fmt.Errorf("listener %s: protocol %s requires a non-tls listener", l.Name, l.Protocol)
+            }
+        default:
+            return nil, fmt.Errorf("listener %s: unknown protocol %q", l.Name, l.Protocol)
+        }
+
+        if l.HttpsRedirect && l.Tls {
+            return nil, fmt.Errorf("listener %s: httpsRedirect requires a non-tls listener", l.Name)
+        }
+
+        if len(l.Routes) == 0 {
+            l.Routes = DefaultRoutes
+        }
+        // The debug routes leak internals, so they either get an admin listener
+        // of their own or are guarded by a token
+        if contains(l.Routes, RoutesDebug) && (contains(l.Routes, RoutesApi) || contains(l.Routes, RoutesStatic)) && c.Debug.Token == "" {
+            return nil, fmt.Errorf("listener %s: the debug routes can only be mounted alongside the api or static routes if DEBUG_TOKEN is set", l.Name)
+        }
+    }
+    return listeners, nil
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
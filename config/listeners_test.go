@@ -0,0 +1,114 @@
+package config
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestListenerConfigs(t *testing.T) {
+    tests := []struct {
+        name      string
+        listeners string
+        tlsCert   string
+        debug     string
+        want      []ListenerConfig
+        wantErr   bool
+    }{
+        {
+            name: "Default HTTP listener",
+            want: []ListenerConfig{
+                {Name: "HTTP", Addr: "0.0.0.0:8080", Protocol: ProtocolHttp1, Routes: DefaultRoutes},
+            },
+        },
+        {
+            name:    "Default HTTP and TLS listeners",
+            tlsCert: "cert.pem",
+            want: []ListenerConfig{
+                {Name: "HTTP", Addr: "0.0.0.0:8080", Protocol: ProtocolHttp1, Routes: DefaultRoutes},
+                {Name: "TLS", Addr: "0.0.0.0:8443", Tls: true, TlsCertPath: "cert.pem", TlsKeyPath: "cert.key", Protocol: ProtocolH2, Routes: DefaultRoutes},
+            },
+        },
+        {
+            name:      "Declared listeners",
+            tlsCert:   "cert.pem",
+            listeners: `[{"name":"public","addr":":8443","tls":true,"routes":["api","static"]},{"name":"admin","addr":"127.0.0.1:9090","routes":["ops"]}]`,
+            want: []ListenerConfig{
+                {Name: "public", Addr: ":8443", Tls: true, TlsCertPath: "cert.pem", TlsKeyPath: "cert.key", Protocol: ProtocolH2, Routes: []string{RoutesApi, RoutesStatic}},
+                {Name: "admin", Addr: "127.0.0.1:9090", Protocol: ProtocolHttp1, Routes: []string{RoutesOps}},
+            },
+        },
+        {
+            name:      "HTTPS redirect on a TLS listener",
+            listeners: `[{"name":"a","addr":":1","tls":true,"httpsRedirect":true}]`,
+            tlsCert:   "cert.pem",
+            wantErr:   true,
+        },
+        {
+            name:      "h2c on a TLS listener",
+            listeners: `[{"name":"a","addr":":1","tls":true,"protocol":"h2c"}]`,
+            tlsCert:   "cert.pem",
+            wantErr:   true,
+        },
+        {
+            name:      "Invalid JSON",
+            listeners: `[{"name":`,
+            wantErr:   true,
+        },
+        {
+            name:      "Duplicate names",
+            listeners: `[{"name":"a","addr":":1"},{"name":"a","addr":":2"}]`,
+            wantErr:   true,
+        },
+        {
+            name:      "TLS without a cert",
+            listeners: `[{"name":"a","addr":":1","tls":true}]`,
+            wantErr:   true,
+        },
+        {
+            name:      "Debug routes on an admin listener",
+            listeners: `[{"name":"admin","addr":"127.0.0.1:9090","routes":["ops","debug"]}]`,
+            want: []ListenerConfig{
+                {Name: "admin", Addr: "127.0.0.1:9090", Protocol: ProtocolHttp1, Routes: []string{RoutesOps, RoutesDebug}},
+            },
+        },
+        {
+            name:      "Debug routes on a public listener",
+            listeners: `[{"name":"public","addr":":8080","routes":["api","debug"]}]`,
+            wantErr:   true,
+        },
+        {
+            name:      "Debug routes on a public listener with a token",
+            listeners: `[{"name":"public","addr":":8080","routes":["api","debug"]}]`,
+            debug:     "secret",
+            want: []ListenerConfig{
+                {Name: "public", Addr: ":8080", Protocol: ProtocolHttp1, Routes: []string{RoutesApi, RoutesDebug}},
+            },
+        },
+        {
+            name:      "h2 without TLS",
+            listeners: `[{"name":"a","addr":":1","protocol":"h2"}]`,
+            wantErr:   true,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            cfg := &Config{}
+            cfg.Server.Host = "0.0.0.0"
+            cfg.Server.HttpPort = 8080
+            cfg.Server.HttpsPort = 8443
+            cfg.Server.TlsCertPath = tt.tlsCert
+            cfg.Server.TlsKeyPath = "cert.key"
+            cfg.Server.Listeners = tt.listeners
+            cfg.Debug.Token = tt.debug
+
+            got, err := cfg.ListenerConfigs()
+            if (err != nil) != tt.wantErr {
+                t.Errorf("ListenerConfigs() error = %v, wantErr %v", err, tt.wantErr)
+                return
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("ListenerConfigs() = %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "strings"
+)
+
+// Rate limit client keys
+const (
+    RateLimitKeyIp        = "ip"        // The client's IP address
+    RateLimitKeyPrincipal = "principal" // The Basic auth user or bearer token of the request from the IP, falling back to the IP
+    RateLimitKeyHeader    = "header:"   // Prefix of header:<name>, e.g. header:X-Api-Key, of the request from the IP, falling back to the IP
+)
+
+// RateLimitAllRoutes is the route of a limit applying to every route without one of its own.
+const RateLimitAllRoutes = "*"
+
+// RateLimitConfig is a token bucket limit on the requests each client can make to a route.
+type RateLimitConfig struct {
+    Route string  `json:"route"` // Route template, e.g. /v1/things/{name}, or * for all other routes
+    Rate  float64 `json:"rate"`  // Requests per second
+    Burst int     `json:"burst"` // Requests that can be made at once. Defaults to the rate (at least 1)
+    Key   string  `json:"key"`   // How clients are told apart: ip (the default), principal or header:<name>
+}
+
+// DefaultRateLimits are the limits applied if RATE_LIMITS isn't set. Every
+// POST /v1/bearer-token hits the Kubernetes API.
+var DefaultRateLimits = []RateLimitConfig{
+    {Route: "/v1/bearer-token", Rate: 1, Burst: 5, Key: RateLimitKeyIp},
+}
+
+// RateLimitConfigs returns the app's rate limits, read from the JSON array in
+// RATE_LIMITS if set, e.g.:
+//
+//  [{"route":"/v1/bearer-token","rate":0.5,"burst":5,"key":"principal"},
+//   {"route":"*","rate":50,"burst":100}]
+//
+// Otherwise they're DefaultRateLimits. There are none if RATE_LIMIT_ENABLED is false.
+func (c *Config) RateLimitConfigs() ([]RateLimitConfig, error) {
+    if !c.RateLimit.Enabled {
+        return nil, nil
+    }
+    if c.RateLimit.Limits == "" {
+        return DefaultRateLimits, nil
+    }
+    var limits []RateLimitConfig
+    if err := json.Unmarshal([]byte(c.RateLimit.Limits), &limits); err != nil {
+        return nil, fmt.Errorf("invalid RATE_LIMITS: %w", err)
+    }
+
+    routes := map[string]bool{}
+    for i := range limits {
+        l := &limits[i]
+        if l.Route == "" {
+            return nil, fmt.Errorf("rate limit #%d: route is required", i+1)
+        }
+        if routes[l.Route] {
+            return nil, fmt.Errorf("rate limit %s: duplicate route", l.Route)
+        }
+        routes[l.Route] = true
+
+        if l.Rate <= 0 {
+            return nil, fmt.Errorf("rate limit %s: rate must be positive", l.Route)
+        }
+        if l.Burst < 0 {
+            return nil, fmt.Errorf("rate limit %s: burst can't be negative", l.Route)
+        }
+        if l.Burst == 0 {
+            l.Burst = int(math.Max(1, math.Ceil(l.Rate)))
+        }
+
+        switch {
+        case l.Key == "":
+            l.Key = RateLimitKeyIp
+        case l.Key == RateLimitKeyIp, l.Key == RateLimitKeyPrincipal:
+        case strings.HasPrefix(l.Key, RateLimitKeyHeader) && len(l.Key) > len(RateLimitKeyHeader):
+        default:
+            return nil, fmt.Errorf("rate limit %s: unknown key %q", l.Route, l.Key)
+        }
+    }
+    return limits, nil
+}
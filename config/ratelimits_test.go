@@ -0,0 +1,76 @@
+package config
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestRateLimitConfigs(t *testing.T) {
+    tests := []struct {
+        name     string
+        disabled bool
+        limits   string
+        want     []RateLimitConfig
+        wantErr  bool
+    }{
+        {
+            name: "Default limits",
+            want: DefaultRateLimits,
+        },
+        {
+            name:     "Disabled",
+            disabled: true,
+            limits:   `[{"route":"*","rate":1}]`,
+        },
+        {
+            name:   "Declared limits",
+            limits: `[{"route":"/v1/bearer-token","rate":0.5,"burst":5,"key":"principal"},{"route":"*","rate":2.5},{"route":"/v1/ping","rate":1,"key":"header:X-Api-Key"}]`,
+            want: []RateLimitConfig{
+                {Route: "/v1/bearer-token", Rate: 0.5, Burst: 5, Key: RateLimitKeyPrincipal},
+                {Route: "*", Rate: 2.5, Burst: 3, Key: RateLimitKeyIp},
+                {Route: "/v1/ping", Rate: 1, Burst: 1, Key: "header:X-Api-Key"},
+            },
+        },
+        {
+            name:    "Invalid JSON",
+            limits:  `[{"route":`,
+            wantErr: true,
+        },
+        {
+            name:    "Duplicate routes",
+            limits:  `[{"route":"*","rate":1},{"route":"*","rate":2}]`,
+            wantErr: true,
+        },
+        {
+            name:    "No rate",
+            limits:  `[{"route":"*"}]`,
+            wantErr: true,
+        },
+        {
+            name:    "Unknown key",
+            limits:  `[{"route":"*","rate":1,"key":"cookie"}]`,
+            wantErr: true,
+        },
+        {
+            name:    "Header key without a name",
+            limits:  `[{"route":"*","rate":1,"key":"header:"}]`,
+            wantErr: true,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            cfg := &Config{}
+            cfg.RateLimit.Enabled = !tt.disabled
+            cfg.RateLimit.Limits = tt.limits
+
+            got, err := cfg.RateLimitConfigs()
+            if (err != nil) != tt.wantErr {
+                t.Errorf("RateLimitConfigs() error = %v, wantErr %v", err, tt.wantErr)
+                return
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("RateLimitConfigs() = %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
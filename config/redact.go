@@ -0,0 +1,38 @@
+package config
+
+import (
+    "log/slog"
+    "strings"
+)
+
+// Redacted replaces the secret config values in logs.
+const Redacted = "[REDACTED]"
+
+// loggedConfig is Config without its LogValue method.
+type loggedConfig Config
+
+// LogValue logs the config with its secret values redacted, so it can be
+// logged at startup.
+func (c Config) LogValue() slog.Value {
+    // Slices are replaced rather than changed in place, as they're shared with c
+    headers := make([]string, len(c.Tracing.OtlpHeaders))
+    for i, header := range c.Tracing.OtlpHeaders {
+        // Keep the header's name, e.g. Authorization=[REDACTED]
+        name, _, _ := strings.Cut(header, "=")
+        headers[i] = name + "=" + Redacted
+    }
+    c.Tracing.OtlpHeaders = headers
+    c.Health.RedisPassword = redact(c.Health.RedisPassword)
+    // DSNs usually hold the database's credentials
+    c.Health.SqlDsn = redact(c.Health.SqlDsn)
+    c.Debug.Token = redact(c.Debug.Token)
+    return slog.AnyValue(loggedConfig(c))
+}
+
+// redact returns Redacted in place of a secret value, if it's set.
+func redact(value string) string {
+    if value == "" {
+        return ""
+    }
+    return Redacted
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+    "bytes"
+    "log/slog"
+    "strings"
+    "testing"
+)
+
+func TestLogValue(t *testing.T) {
+    cfg := &Config{}
+    cfg.Tracing.OtlpEndpoint = "https://collector:4318/v1/traces"
+    cfg.Tracing.OtlpHeaders = []string{"Authorization=Basic c2VjcmV0"}
+    cfg.Health.RedisAddrs = []string{"redis:6379"}
+    cfg.Health.RedisPassword = "redis-password"
+    cfg.Health.SqlDriver = "postgres"
+    cfg.Health.SqlDsn = "postgres://app:db-password@db/app"
+    cfg.Debug.Token = "debug-token"
+
+    var buf bytes.Buffer
+    slog.New(slog.NewJSONHandler(&buf, nil)).Info("App config", "config", cfg)
+    logged := buf.String()
+    for _, secret := range []string{"c2VjcmV0", "redis-password", "db-password", "debug-token"} {
+        if strings.Contains(logged, secret) {
+            t.Errorf("secret %q was logged: %s", secret, logged)
+        }
+    }
+    for _, want := range []string{`"Authorization=[REDACTED]"`, "https://collector:4318/v1/traces", `"RedisPassword":"[REDACTED]"`, `"SqlDsn":"[REDACTED]"`, `"Token":"[REDACTED]"`, "redis:6379"} {
+        if !strings.Contains(logged, want) {
+            t.Errorf("%s wasn't logged: %s", want, logged)
+        }
+    }
+    if cfg.Tracing.OtlpHeaders[0] != "Authorization=Basic c2VjcmV0" {
+        t.Errorf("logging changed the config: %v", cfg.Tracing.OtlpHeaders)
+    }
+}
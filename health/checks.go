@@ -0,0 +1,148 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// TCP returns a check that a TCP connection to addr (host:port) can be opened.
+func TCP(addr string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// Redis returns a check that the Redis server at addr (host:port) answers a
+// PING, authenticating first with password if it's set.
+func Redis(addr, password string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		r := bufio.NewReader(conn)
+		if password != "" {
+			if err := redisCommand(conn, r, "+OK", "AUTH", password); err != nil {
+				return fmt.Errorf("AUTH: %w", err)
+			}
+		}
+		if err := redisCommand(conn, r, "+PONG", "PING"); err != nil {
+			return fmt.Errorf("PING: %w", err)
+		}
+		return nil
+	}
+}
+
+// redisCommand sends a command as a RESP array of bulk strings and checks
+// that the reply is the simple string want.
+func redisCommand(conn net.Conn, r *bufio.Reader, want string, args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	reply, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	reply = strings.TrimRight(reply, "\r\n")
+	switch {
+	case reply == want:
+		return nil
+	case strings.HasPrefix(reply, "-"):
+		return errors.New(reply[1:])
+	default:
+		return fmt.Errorf("unexpected reply %q", reply)
+	}
+}
+
+// SQL returns a check that db answers a SELECT 1.
+func SQL(db *sql.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var one int
+		return db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+	}
+}
+
+// CertExpiry returns a check that none of the PEM certs in the files at paths
+// expires within warn. Its error says how many days the first one to expire
+// has left, e.g. "CN=gomux1 (tls.crt) expires in 9 days".
+func CertExpiry(paths []string, warn time.Duration, now func() time.Time) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var first *x509.Certificate
+		var firstPath string
+		for _, path := range paths {
+			certs, err := readCerts(path)
+			if err != nil {
+				return err
+			}
+			for _, cert := range certs {
+				if first == nil || cert.NotAfter.Before(first.NotAfter) {
+					first, firstPath = cert, path
+				}
+			}
+		}
+		if first == nil {
+			return nil
+		}
+		left := first.NotAfter.Sub(now())
+		switch {
+		case left <= 0:
+			return fmt.Errorf("%s (%s) expired on %s", first.Subject, firstPath, first.NotAfter.UTC().Format(time.RFC3339))
+		case left < warn:
+			return fmt.Errorf("%s (%s) expires in %d days", first.Subject, firstPath, int(math.Floor(left.Hours()/24)))
+		}
+		return nil
+	}
+}
+
+// readCerts parses the PEM certs in the file at path.
+func readCerts(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certs found", path)
+	}
+	return certs, nil
+}
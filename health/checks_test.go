@@ -0,0 +1,229 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTCP(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if err := TCP(addr)(context.Background()); err != nil {
+		t.Errorf("want the check to pass, got %v", err)
+	}
+	ln.Close()
+	if err := TCP(addr)(context.Background()); err == nil {
+		t.Error("want the check to fail once the listener is closed")
+	}
+}
+
+// serveRedis serves a minimal stand-in of a Redis server, requiring password
+// if it's set, and returns its address.
+func serveRedis(t *testing.T, password string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					args, err := readRESPArray(r)
+					if err != nil {
+						return
+					}
+					switch {
+					case args[0] == "AUTH" && len(args) == 2 && args[1] == password:
+						authed = true
+						io.WriteString(conn, "+OK\r\n")
+					case args[0] == "AUTH":
+						io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+					case !authed:
+						io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+					case args[0] == "PING":
+						io.WriteString(conn, "+PONG\r\n")
+					default:
+						io.WriteString(conn, "-ERR unknown command\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// readRESPArray reads a RESP array of bulk strings.
+func readRESPArray(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	var n int
+	if _, err := fmt.Sscanf(line, "*%d\r\n", &n); err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		// Skip the $<size> line
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimRight(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	t.Parallel()
+	addr := serveRedis(t, "")
+	if err := Redis(addr, "")(context.Background()); err != nil {
+		t.Errorf("want the check to pass, got %v", err)
+	}
+
+	addr = serveRedis(t, "secret")
+	if err := Redis(addr, "secret")(context.Background()); err != nil {
+		t.Errorf("want the check to pass with the password, got %v", err)
+	}
+	if err := Redis(addr, "")(context.Background()); err == nil || !strings.Contains(err.Error(), "NOAUTH") {
+		t.Errorf("want a NOAUTH error, got %v", err)
+	}
+	if err := Redis(addr, "wrong")(context.Background()); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("want a WRONGPASS error, got %v", err)
+	}
+}
+
+// testDriver is a database/sql driver whose queries all fail with err if it's set.
+type testDriver struct{ err error }
+
+func (d *testDriver) Open(name string) (driver.Conn, error) { return &testConn{d}, nil }
+
+type testConn struct{ d *testDriver }
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) { return &testStmt{c.d}, nil }
+func (c *testConn) Close() error                              { return nil }
+func (c *testConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type testStmt struct{ d *testDriver }
+
+func (s *testStmt) Close() error                                    { return nil }
+func (s *testStmt) NumInput() int                                   { return 0 }
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, s.d.err }
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.d.err != nil {
+		return nil, s.d.err
+	}
+	return &testRows{}, nil
+}
+
+type testRows struct{ done bool }
+
+func (r *testRows) Columns() []string { return []string{"1"} }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func TestSQL(t *testing.T) {
+	t.Parallel()
+	d := &testDriver{}
+	sql.Register("health-test", d)
+	db, err := sql.Open("health-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := SQL(db)(context.Background()); err != nil {
+		t.Errorf("want the check to pass, got %v", err)
+	}
+	d.err = errors.New("database is down")
+	if err := SQL(db)(context.Background()); err == nil || err.Error() != "database is down" {
+		t.Errorf("want the query's error, got %v", err)
+	}
+}
+
+// writeCert writes a self-signed cert for cn expiring at notAfter and returns its path.
+func writeCert(t *testing.T, cn string, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), cn+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCertExpiry(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	leaf := writeCert(t, "leaf", now.Add(90*24*time.Hour))
+	ca := writeCert(t, "ca", now.Add(9*24*time.Hour+time.Hour))
+
+	if err := CertExpiry([]string{leaf}, 14*24*time.Hour, clock)(context.Background()); err != nil {
+		t.Errorf("want the check to pass, got %v", err)
+	}
+	err := CertExpiry([]string{leaf, ca}, 14*24*time.Hour, clock)(context.Background())
+	if err == nil || err.Error() != "CN=ca ("+ca+") expires in 9 days" {
+		t.Errorf("wrong error: %v", err)
+	}
+	expired := writeCert(t, "expired", now.Add(-time.Hour))
+	if err := CertExpiry([]string{expired}, 0, clock)(context.Background()); err == nil || !strings.Contains(err.Error(), "expired on") {
+		t.Errorf("want an expired error, got %v", err)
+	}
+	if err := CertExpiry([]string{filepath.Join(t.TempDir(), "missing.pem")}, 0, clock)(context.Background()); err == nil {
+		t.Error("want the check to fail for a missing file")
+	}
+}
@@ -0,0 +1,279 @@
+// Package health runs the named checks components register and reports their
+// results for the liveness, readiness and startup probes. Checks run in the
+// background and probes report their latest results, so a probe never blocks
+// on a slow dependency.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Probe is a kind of health probe, as in Kubernetes.
+type Probe string
+
+const (
+	Liveness  Probe = "liveness"  // Is the process working at all? Failing gets it restarted
+	Readiness Probe = "readiness" // Can it serve traffic right now? Failing takes it out of rotation
+	Startup   Probe = "startup"   // Has it finished starting? Passes for good once all its checks have passed
+)
+
+// Check statuses
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusWarn    = "warn"    // Probe only: a non-critical check is failing
+	StatusPending = "pending" // Check only: it hasn't completed yet
+)
+
+// Defaults of Check
+const (
+	DefaultTimeout  = 5 * time.Second
+	DefaultInterval = 10 * time.Second
+)
+
+// Check is a named health check.
+type Check struct {
+	Name string
+	// Probes are the probes the check affects. Defaults to readiness only.
+	Probes []Probe
+	// Critical checks fail the probes they affect. Others are reported, but
+	// only make the probes warn.
+	Critical bool
+	// Timeout is the deadline of the context passed to Check. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// Interval is how often the check runs. Defaults to DefaultInterval.
+	Interval time.Duration
+	// Instant checks run on every probe instead of in the background, and
+	// must return right away, e.g. because they only look at in-memory state.
+	Instant bool
+	// Check returns an error if the component is unhealthy.
+	Check func(ctx context.Context) error
+}
+
+// affects reports whether c affects probe.
+func (c *Check) affects(probe Probe) bool {
+	for _, p := range c.Probes {
+		if p == probe {
+			return true
+		}
+	}
+	return false
+}
+
+// Result is the latest outcome of a check.
+type Result struct {
+	Name          string  `json:"name"`
+	Status        string  `json:"status"` // pass, fail or pending
+	Critical      bool    `json:"critical"`
+	Probes        []Probe `json:"probes"`
+	LatencyMs     float64 `json:"latencyMs"`
+	LastChecked   string  `json:"lastChecked,omitempty"`
+	LastError     string  `json:"lastError,omitempty"`     // The error of the last failed run, even if the check has passed since
+	LastErrorTime string  `json:"lastErrorTime,omitempty"` // When it failed
+}
+
+// Report is the status of a probe and the results of the checks affecting it.
+type Report struct {
+	Healthy bool     `json:"healthy"`
+	Status  string   `json:"status"` // pass, warn (a non-critical check is failing) or fail
+	Checks  []Result `json:"checks"`
+}
+
+// Registry holds the registered checks and their latest results.
+type Registry struct {
+	clock func() time.Time
+
+	mu      sync.Mutex
+	checks  []*entry
+	started bool
+	ctx     context.Context // Of Start
+	passed  bool            // Whether the startup probe has passed
+}
+
+type entry struct {
+	check   Check
+	result  Result
+	trigger chan struct{} // Makes the check run now
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{clock: time.Now}
+}
+
+// Register adds a check. Checks registered after Start start running right
+// away. It panics if a check of the same name is already registered or the
+// check is invalid, since that's a programming error.
+func (r *Registry) Register(check Check) {
+	if check.Name == "" || check.Check == nil {
+		panic("health: a check needs a name and a Check func")
+	}
+	if len(check.Probes) == 0 {
+		check.Probes = []Probe{Readiness}
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultTimeout
+	}
+	if check.Interval <= 0 {
+		check.Interval = DefaultInterval
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.checks {
+		if e.check.Name == check.Name {
+			panic(fmt.Sprintf("health: check %s is already registered", check.Name))
+		}
+	}
+	e := &entry{
+		check:   check,
+		result:  Result{Name: check.Name, Status: StatusPending, Critical: check.Critical, Probes: check.Probes},
+		trigger: make(chan struct{}, 1),
+	}
+	r.checks = append(r.checks, e)
+	sort.Slice(r.checks, func(i, j int) bool { return r.checks[i].check.Name < r.checks[j].check.Name })
+	if r.started && !check.Instant {
+		go r.run(r.ctx, e)
+	}
+}
+
+// Start runs the checks in the background, each right away and then every
+// Interval, until ctx is done. Calling it more than once has no effect.
+func (r *Registry) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+	r.ctx = ctx
+	for _, e := range r.checks {
+		if !e.check.Instant {
+			go r.run(ctx, e)
+		}
+	}
+}
+
+// Refresh makes the background checks run now, without waiting for their next interval.
+func (r *Registry) Refresh() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.checks {
+		select {
+		case e.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run runs the check of e every interval until ctx is done.
+func (r *Registry) run(ctx context.Context, e *entry) {
+	ticker := time.NewTicker(e.check.Interval)
+	defer ticker.Stop()
+	for {
+		result := r.runCheck(ctx, e.check)
+		r.mu.Lock()
+		r.record(e, result)
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-e.trigger:
+		}
+	}
+}
+
+// runCheck runs check once, returning its result (without its error history).
+func (r *Registry) runCheck(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+	start := r.clock()
+	err := safeCheck(ctx, check.Check)
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %v", check.Timeout)
+	}
+	result := Result{
+		Name:        check.Name,
+		Status:      StatusPass,
+		Critical:    check.Critical,
+		Probes:      check.Probes,
+		LatencyMs:   float64(r.clock().Sub(start).Microseconds()) / 1000,
+		LastChecked: start.UTC().Format(time.RFC3339Nano),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.LastError = err.Error()
+		result.LastErrorTime = result.LastChecked
+	}
+	return result
+}
+
+// safeCheck runs check, turning a panic into an error.
+func safeCheck(ctx context.Context, check func(context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return check(ctx)
+}
+
+// record stores the result of a run of e's check. r.mu must be held.
+func (r *Registry) record(e *entry, result Result) {
+	if result.LastError == "" {
+		// Keep the error history
+		result.LastError, result.LastErrorTime = e.result.LastError, e.result.LastErrorTime
+	}
+	e.result = result
+}
+
+// Report returns the status of probe from the latest results of the checks
+// affecting it, or of all checks if probe is "". Instant checks are run
+// first. Pending checks fail the readiness and startup probes but not the
+// liveness probe, which mustn't fail just because a check is slow to complete.
+func (r *Registry) Report(ctx context.Context, probe Probe) Report {
+	r.mu.Lock()
+	var instant []*entry
+	for _, e := range r.checks {
+		if e.check.Instant && (probe == "" || e.check.affects(probe)) {
+			instant = append(instant, e)
+		}
+	}
+	r.mu.Unlock()
+	for _, e := range instant {
+		result := r.runCheck(ctx, e.check)
+		r.mu.Lock()
+		r.record(e, result)
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := Report{Healthy: true, Status: StatusPass, Checks: []Result{}}
+	if probe == Startup && r.passed {
+		// Once started, always started
+		return report
+	}
+	for _, e := range r.checks {
+		if probe != "" && !e.check.affects(probe) {
+			continue
+		}
+		report.Checks = append(report.Checks, e.result)
+		failing := e.result.Status == StatusFail || (e.result.Status == StatusPending && probe != Liveness)
+		switch {
+		case failing && e.check.Critical:
+			report.Healthy = false
+			report.Status = StatusFail
+		case failing && report.Status == StatusPass:
+			report.Status = StatusWarn
+		}
+	}
+	if probe == Startup && report.Healthy {
+		r.passed = true
+	}
+	return report
+}
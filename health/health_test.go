@@ -0,0 +1,169 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor waits until the check name of r is no longer pending.
+func waitFor(t *testing.T, r *Registry, name string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		for _, result := range r.Report(context.Background(), "").Checks {
+			if result.Name == name && result.Status != StatusPending {
+				return
+			}
+		}
+	}
+	t.Fatalf("check %s didn't complete", name)
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var dbUp atomic.Bool
+	r.Register(Check{Name: "db", Critical: true, Interval: time.Hour, Check: func(ctx context.Context) error {
+		if !dbUp.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}})
+	r.Register(Check{Name: "cache", Interval: time.Hour, Check: func(ctx context.Context) error {
+		return errors.New("cache down")
+	}})
+	r.Register(Check{Name: "deadlock", Probes: []Probe{Liveness}, Critical: true, Interval: time.Hour, Check: func(ctx context.Context) error {
+		return nil
+	}})
+
+	// Before the checks complete, readiness fails and liveness passes
+	if report := r.Report(context.Background(), Readiness); report.Healthy || report.Checks[0].Status != StatusPending {
+		t.Errorf("readiness should fail while checks are pending: %+v", report)
+	}
+	if report := r.Report(context.Background(), Liveness); !report.Healthy {
+		t.Errorf("liveness should pass while checks are pending: %+v", report)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+	for _, name := range []string{"db", "cache", "deadlock"} {
+		waitFor(t, r, name)
+	}
+
+	report := r.Report(context.Background(), Readiness)
+	if report.Healthy || report.Status != StatusFail || len(report.Checks) != 2 {
+		t.Fatalf("wrong readiness report: %+v", report)
+	}
+	cache, db := report.Checks[0], report.Checks[1]
+	if db.Name != "db" || db.Status != StatusFail || db.LastError != "connection refused" || db.LastChecked == "" {
+		t.Errorf("wrong db result: %+v", db)
+	}
+	if cache.Name != "cache" || cache.Critical || cache.Status != StatusFail {
+		t.Errorf("wrong cache result: %+v", cache)
+	}
+	if report := r.Report(context.Background(), Liveness); !report.Healthy || len(report.Checks) != 1 || report.Checks[0].Name != "deadlock" {
+		t.Errorf("wrong liveness report: %+v", report)
+	}
+
+	// Once the critical check passes, the failing non-critical one only makes readiness warn
+	dbUp.Store(true)
+	r.Refresh()
+	for deadline := time.Now().Add(5 * time.Second); r.Report(context.Background(), Readiness).Checks[1].Status != StatusPass; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("db check wasn't refreshed")
+		}
+	}
+	report = r.Report(context.Background(), Readiness)
+	if !report.Healthy || report.Status != StatusWarn {
+		t.Errorf("wrong readiness report: %+v", report)
+	}
+	if db := report.Checks[1]; db.LastError != "connection refused" || db.LastErrorTime == "" {
+		t.Errorf("the last error should be kept: %+v", db)
+	}
+}
+
+func TestInstantChecks(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var ready atomic.Bool
+	r.Register(Check{Name: "server", Critical: true, Instant: true, Check: func(ctx context.Context) error {
+		if !ready.Load() {
+			return errors.New("not ready")
+		}
+		return nil
+	}})
+
+	// Instant checks don't need Start
+	if report := r.Report(context.Background(), Readiness); report.Healthy {
+		t.Errorf("readiness should fail: %+v", report)
+	}
+	ready.Store(true)
+	if report := r.Report(context.Background(), Readiness); !report.Healthy {
+		t.Errorf("readiness should pass: %+v", report)
+	}
+}
+
+func TestStartupPassesOnce(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var migrated atomic.Bool
+	r.Register(Check{Name: "migrations", Probes: []Probe{Startup, Readiness}, Critical: true, Instant: true, Check: func(ctx context.Context) error {
+		if !migrated.Load() {
+			return errors.New("running")
+		}
+		return nil
+	}})
+	if r.Report(context.Background(), Startup).Healthy {
+		t.Error("startup should fail")
+	}
+	migrated.Store(true)
+	if !r.Report(context.Background(), Startup).Healthy {
+		t.Error("startup should pass")
+	}
+	migrated.Store(false)
+	if !r.Report(context.Background(), Startup).Healthy {
+		t.Error("startup should keep passing")
+	}
+	if r.Report(context.Background(), Readiness).Healthy {
+		t.Error("readiness should fail again")
+	}
+}
+
+func TestCheckTimeoutAndPanic(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.Register(Check{Name: "slow", Critical: true, Timeout: 10 * time.Millisecond, Interval: time.Hour, Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}})
+	r.Register(Check{Name: "panics", Interval: time.Hour, Check: func(ctx context.Context) error {
+		panic("boom")
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+	waitFor(t, r, "slow")
+	waitFor(t, r, "panics")
+	report := r.Report(context.Background(), Readiness)
+	if panics := report.Checks[0]; panics.LastError != "panic: boom" {
+		t.Errorf("wrong panics result: %+v", panics)
+	}
+	if slow := report.Checks[1]; slow.Status != StatusFail || slow.LastError != "timed out after 10ms" {
+		t.Errorf("wrong slow result: %+v", slow)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.Register(Check{Name: "db", Check: func(ctx context.Context) error { return nil }})
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate check should panic")
+		}
+	}()
+	r.Register(Check{Name: "db", Check: func(ctx context.Context) error { return nil }})
+}
@@ -0,0 +1,108 @@
+package loadshed
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit decides how many requests can be in flight at once. Adaptive limits
+// adjust it from the latency of the requests they observe.
+type Limit interface {
+	// Limit returns the current limit.
+	Limit() int
+	// Observe records a completed request: its latency, and whether it was
+	// dropped, e.g. because it timed out.
+	Observe(latency time.Duration, dropped bool)
+}
+
+// Static is a fixed limit.
+type Static int
+
+func (s Static) Limit() int {
+	return int(s)
+}
+
+func (Static) Observe(time.Duration, bool) {}
+
+// AIMD is an additive increase/multiplicative decrease limit: it grows by one
+// request per limit's worth of requests completing within the target latency,
+// and is multiplied by the backoff ratio when one is slower or dropped.
+type AIMD struct {
+	mu       sync.Mutex
+	limit    float64
+	min, max float64
+	target   time.Duration
+	backoff  float64
+}
+
+// NewAIMD returns an AIMD limit starting at initial, kept between min and max,
+// backing off by 10% whenever a request is slower than target.
+func NewAIMD(initial, min, max int, target time.Duration) *AIMD {
+	return &AIMD{limit: float64(initial), min: float64(min), max: float64(max), target: target, backoff: 0.9}
+}
+
+func (a *AIMD) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+func (a *AIMD) Observe(latency time.Duration, dropped bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if dropped || latency > a.target {
+		a.limit = math.Max(a.min, a.limit*a.backoff)
+	} else {
+		a.limit = math.Min(a.max, a.limit+1/a.limit)
+	}
+}
+
+// Gradient is a limit following the gradient between the shortest latency
+// seen recently (with no queueing) and the current latency, in the manner of
+// Netflix's concurrency-limits: while latency stays close to the shortest the
+// limit grows by its square root, and it shrinks in proportion as latency rises.
+type Gradient struct {
+	mu         sync.Mutex
+	limit      float64
+	min, max   float64
+	smoothing  float64
+	minLatency time.Duration // Shortest latency since the last reset
+	resetAt    time.Time
+	window     time.Duration // How often minLatency is reset, to follow changes of the baseline
+	clock      func() time.Time
+}
+
+// NewGradient returns a Gradient limit starting at initial, kept between min and max.
+func NewGradient(initial, min, max int) *Gradient {
+	return &Gradient{limit: float64(initial), min: float64(min), max: float64(max), smoothing: 0.2, window: time.Minute, clock: time.Now}
+}
+
+func (g *Gradient) Limit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return int(g.limit)
+}
+
+func (g *Gradient) Observe(latency time.Duration, dropped bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now := g.clock(); now.After(g.resetAt) {
+		g.minLatency = 0
+		g.resetAt = now.Add(g.window)
+	}
+	if latency <= 0 {
+		latency = time.Nanosecond
+	}
+	if g.minLatency == 0 || latency < g.minLatency {
+		g.minLatency = latency
+	}
+
+	// Between 0.5 (latency has doubled) and 1 (latency is at its shortest)
+	gradient := math.Max(0.5, math.Min(1, float64(g.minLatency)/float64(latency)))
+	if dropped {
+		gradient = 0.5
+	}
+	target := g.limit*gradient + math.Sqrt(g.limit)
+	g.limit = math.Min(g.max, math.Max(g.min, (1-g.smoothing)*g.limit+g.smoothing*target))
+}
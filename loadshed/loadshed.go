@@ -0,0 +1,166 @@
+// Package loadshed limits the number of requests served at once, queueing or
+// shedding the rest depending on their priority.
+package loadshed
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrShed is returned by Acquire when a request is shed.
+var ErrShed = errors.New("over capacity")
+
+// Priority is how a request is treated when the limit is reached.
+type Priority int
+
+const (
+	PriorityLow      Priority = iota // Shed right away
+	PriorityNormal                   // Queued for up to the queue timeout, then shed
+	PriorityCritical                 // Never shed, and not counted against the limit
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityNormal:
+		return "normal"
+	default:
+		return "low"
+	}
+}
+
+// Limiter admits requests while fewer than its Limit are in flight. Normal
+// priority requests arriving over the limit wait in a FIFO queue.
+type Limiter struct {
+	limit        Limit
+	queueSize    int
+	queueTimeout time.Duration
+	clock        func() time.Time
+
+	mu       sync.Mutex
+	inFlight int
+	queue    list.List // Of *waiter
+}
+
+type waiter struct {
+	ready   chan struct{} // Closed when the waiter is admitted
+	element *list.Element
+}
+
+// New returns a Limiter admitting up to limit requests at once, with up to
+// queueSize normal priority requests waiting for up to queueTimeout.
+func New(limit Limit, queueSize int, queueTimeout time.Duration) *Limiter {
+	return &Limiter{limit: limit, queueSize: queueSize, queueTimeout: queueTimeout, clock: time.Now}
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	return l.limit.Limit()
+}
+
+// InFlight returns the number of admitted requests that haven't been released.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// Queued returns the number of requests waiting to be admitted.
+func (l *Limiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queue.Len()
+}
+
+// QueueTimeout returns how long normal priority requests wait before being shed.
+func (l *Limiter) QueueTimeout() time.Duration {
+	return l.queueTimeout
+}
+
+// Acquire admits a request of priority p, waiting in the queue if p is normal
+// and the limit is reached. It returns ErrShed if the request is shed, or the
+// error of ctx if it's done first. Admitted requests must be released with
+// Token.Release once served.
+func (l *Limiter) Acquire(ctx context.Context, p Priority) (*Token, error) {
+	if p == PriorityCritical {
+		return &Token{}, nil
+	}
+	l.mu.Lock()
+	if l.inFlight < l.limit.Limit() && l.queue.Len() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.newToken(), nil
+	}
+	if p != PriorityNormal || l.queue.Len() >= l.queueSize {
+		l.mu.Unlock()
+		return nil, ErrShed
+	}
+	w := &waiter{ready: make(chan struct{})}
+	w.element = l.queue.PushBack(w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-w.ready:
+		return l.newToken(), nil
+	case <-timer.C:
+		err = ErrShed
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-w.ready:
+		// Admitted while giving up: hand the slot to the next waiter
+		l.inFlight--
+		l.admit()
+	default:
+		l.queue.Remove(w.element)
+	}
+	return nil, err
+}
+
+func (l *Limiter) newToken() *Token {
+	return &Token{limiter: l, start: l.clock()}
+}
+
+// admit lets queued requests in while there's room. l.mu must be held.
+func (l *Limiter) admit() {
+	for l.queue.Len() > 0 && l.inFlight < l.limit.Limit() {
+		w := l.queue.Remove(l.queue.Front()).(*waiter)
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// Token is a request admitted by a Limiter.
+type Token struct {
+	limiter *Limiter // nil for critical requests
+	start   time.Time
+	once    sync.Once
+}
+
+// Release frees the request's slot and reports its latency to the limit.
+// dropped reports whether the request failed because of the load, e.g. it
+// timed out. Only the first call has any effect.
+func (t *Token) Release(dropped bool) {
+	if t.limiter == nil {
+		return
+	}
+	t.once.Do(func() {
+		l := t.limiter
+		l.limit.Observe(l.clock().Sub(t.start), dropped)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.inFlight--
+		l.admit()
+	})
+}
@@ -0,0 +1,136 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	t.Parallel()
+	l := New(Static(2), 1, time.Hour)
+	ctx := context.Background()
+	a, err := l.Acquire(ctx, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(ctx, PriorityLow); err != nil {
+		t.Fatal(err)
+	}
+
+	// Over the limit low priority requests are shed right away...
+	if _, err := l.Acquire(ctx, PriorityLow); !errors.Is(err, ErrShed) {
+		t.Errorf("want a low priority request shed, got %v", err)
+	}
+	// ...critical ones always get in...
+	critical, err := l.Acquire(ctx, PriorityCritical)
+	if err != nil {
+		t.Errorf("critical request was shed: %v", err)
+	}
+	critical.Release(false)
+	if l.InFlight() != 2 {
+		t.Errorf("critical requests shouldn't be counted: %v in flight", l.InFlight())
+	}
+
+	// ...and normal ones wait for a slot
+	admitted := make(chan error, 1)
+	go func() {
+		token, err := l.Acquire(ctx, PriorityNormal)
+		if err == nil {
+			token.Release(false)
+		}
+		admitted <- err
+	}()
+	for l.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// The queue is full
+	if _, err := l.Acquire(ctx, PriorityNormal); !errors.Is(err, ErrShed) {
+		t.Errorf("want a request shed when the queue is full, got %v", err)
+	}
+	a.Release(false)
+	a.Release(false) // Only released once
+	if err := <-admitted; err != nil {
+		t.Errorf("queued request wasn't admitted: %v", err)
+	}
+	if l.InFlight() != 1 || l.Queued() != 0 {
+		t.Errorf("wrong state: %v in flight, %v queued", l.InFlight(), l.Queued())
+	}
+}
+
+func TestAcquireQueueTimeout(t *testing.T) {
+	t.Parallel()
+	l := New(Static(1), 10, 10*time.Millisecond)
+	if _, err := l.Acquire(context.Background(), PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := l.Acquire(context.Background(), PriorityNormal); !errors.Is(err, ErrShed) {
+		t.Errorf("want the request shed after the queue timeout, got %v", err)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Errorf("request was shed before the queue timeout")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Acquire(ctx, PriorityNormal); !errors.Is(err, context.Canceled) {
+		t.Errorf("want the context's error, got %v", err)
+	}
+	if l.Queued() != 0 {
+		t.Errorf("requests that gave up are still queued: %v", l.Queued())
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	t.Parallel()
+	a := NewAIMD(10, 5, 11, 100*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		a.Observe(10*time.Millisecond, false)
+	}
+	if a.Limit() != 10 {
+		// 10 + 10 * 1/10ish, just short of 11
+		t.Errorf("wrong limit after fast requests: %v", a.Limit())
+	}
+	for i := 0; i < 10; i++ {
+		a.Observe(10*time.Millisecond, false)
+	}
+	if a.Limit() != 11 {
+		t.Errorf("limit should be capped at the max: %v", a.Limit())
+	}
+	a.Observe(time.Second, false)
+	if a.Limit() != 9 {
+		t.Errorf("wrong limit after a slow request: %v", a.Limit())
+	}
+	for i := 0; i < 10; i++ {
+		a.Observe(0, true)
+	}
+	if a.Limit() != 5 {
+		t.Errorf("limit should be kept at the min: %v", a.Limit())
+	}
+}
+
+func TestGradient(t *testing.T) {
+	t.Parallel()
+	g := NewGradient(20, 5, 100)
+	for i := 0; i < 20; i++ {
+		g.Observe(10*time.Millisecond, false)
+	}
+	grown := g.Limit()
+	if grown <= 20 {
+		t.Errorf("limit should grow while latency is steady: %v", grown)
+	}
+	for i := 0; i < 20; i++ {
+		g.Observe(100*time.Millisecond, false)
+	}
+	if g.Limit() >= grown {
+		t.Errorf("limit should shrink when latency rises: %v -> %v", grown, g.Limit())
+	}
+	for i := 0; i < 100; i++ {
+		g.Observe(10*time.Millisecond, false)
+	}
+	if g.Limit() != 100 {
+		t.Errorf("limit should be capped at the max: %v", g.Limit())
+	}
+}
@@ -0,0 +1,75 @@
+// Package logging sets up the app's structured logger (log/slog) and carries
+// request-scoped loggers through contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Attribute keys used across the app, so log lines can be queried consistently.
+const (
+	KeyError      = "error"
+	KeyRequestID  = "request_id"
+	KeyTraceID    = "trace_id"
+	KeyRoute      = "route"
+	KeyMethod     = "method"
+	KeyRemoteAddr = "remote_addr"
+	KeyListener   = "listener"
+	KeyAddr       = "addr"
+	KeyPath       = "path"
+	KeySignal     = "signal"
+	KeyPid        = "pid"
+	KeyDuration   = "duration"
+	KeyFormat     = "format"
+	KeyPanic      = "panic"
+	KeyStack      = "stack"
+)
+
+// Log formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New returns a logger writing to w in format ("json" or "text") at level
+// ("debug", "info", "warn" or "error").
+func New(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+}
+
+// Err returns the attribute logging err.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if
+// there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("dropped")
+	logger.Warn("kept", KeyListener, "HTTP", Err(errors.New("boom")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("want only the warning, got %q", buf.String())
+	}
+	entry := map[string]any{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "kept" || entry["level"] != "WARN" || entry[KeyListener] != "HTTP" || entry[KeyError] != "boom" {
+		t.Errorf("wrong entry: %v", entry)
+	}
+
+	buf.Reset()
+	logger, err = New(&buf, "TEXT", "DEBUG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("hello", KeyPath, "/x")
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG msg=hello path=/x") {
+		t.Errorf("wrong text entry: %q", got)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	t.Parallel()
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Errorf("invalid format was accepted")
+	}
+	if _, err := New(&bytes.Buffer{}, "json", "loud"); err == nil {
+		t.Errorf("invalid level was accepted")
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Parallel()
+	if FromContext(context.Background()) != slog.Default() {
+		t.Errorf("want the default logger for a context without one")
+	}
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	if FromContext(NewContext(context.Background(), logger)) != logger {
+		t.Errorf("logger wasn't carried by the context")
+	}
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Default is the registry served on /metrics.
+var Default = NewRegistry()
+
+// The app's metrics.
+var (
+	HttpRequests = NewCounterVec("http_requests_total",
+		"HTTP requests served, by route template, method and status class.",
+		"route", "method", "status_class")
+	HttpRequestDuration = NewHistogramVec("http_request_duration_seconds",
+		"Latency of HTTP requests, by route template, method and status class.",
+		nil, "route", "method", "status_class")
+	HttpRequestsInFlight = NewGaugeVec("http_requests_in_flight",
+		"HTTP requests being served, by listener.",
+		"listener")
+	TlsHandshakeErrors = NewCounterVec("tls_handshake_errors_total",
+		"Failed TLS handshakes, by listener.",
+		"listener")
+	HttpPanics = NewCounterVec("http_panics_total",
+		"Panics recovered from while serving HTTP requests, by route template.",
+		"route")
+	HttpRateLimited = NewCounterVec("http_rate_limited_total",
+		"HTTP requests rejected for exceeding a rate limit, by route template.",
+		"route")
+	HttpRequestsShed = NewCounterVec("http_requests_shed_total",
+		"HTTP requests rejected for being over the concurrency limit, by priority class.",
+		"priority")
+	LoadShedLimit = NewGaugeVec("load_shed_limit",
+		"Current limit of HTTP requests served at once.")
+	LoadShedQueued = NewGaugeVec("load_shed_queued",
+		"HTTP requests waiting to be served.")
+	KubernetesRequestDuration = NewHistogramVec("kubernetes_request_duration_seconds",
+		"Latency of Kubernetes API requests, by operation and HTTP status code.",
+		nil, "operation", "code")
+	BuildInfo = NewGaugeVec("build_info",
+		"Always 1, labeled with the app's build info.",
+		"git_sha", "git_branch", "build_timestamp", "go_version")
+)
+
+func init() {
+	Default.Register(HttpRequests, HttpRequestDuration, HttpRequestsInFlight, TlsHandshakeErrors, HttpPanics, HttpRateLimited, HttpRequestsShed, LoadShedLimit, LoadShedQueued,
+		KubernetesRequestDuration, BuildInfo)
+	RegisterRuntime(Default)
+}
+
+// RegisterRuntime registers Go runtime and process metrics with r.
+func RegisterRuntime(r *Registry) {
+	start := float64(time.Now().UnixNano()) / 1e9
+	ms := &memStats{}
+	goInfo := NewGaugeVec("go_info", "Information about the Go environment.", "version")
+	goInfo.With(runtime.Version()).Set(1)
+	r.Register(
+		goInfo,
+		NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+			return float64(runtime.NumGoroutine())
+		}),
+		NewGaugeFunc("go_memstats_alloc_bytes", "Bytes of allocated heap objects.", func() float64 {
+			return float64(ms.get().HeapAlloc)
+		}),
+		NewGaugeFunc("go_memstats_heap_inuse_bytes", "Bytes in in-use heap spans.", func() float64 {
+			return float64(ms.get().HeapInuse)
+		}),
+		NewGaugeFunc("go_memstats_sys_bytes", "Bytes of memory obtained from the OS.", func() float64 {
+			return float64(ms.get().Sys)
+		}),
+		NewCounterFunc("go_gc_cycles_total", "Completed GC cycles.", func() float64 {
+			return float64(ms.get().NumGC)
+		}),
+		NewCounterFunc("go_gc_pause_seconds_total", "Total time spent in GC stop-the-world pauses.", func() float64 {
+			return float64(ms.get().PauseTotalNs) / 1e9
+		}),
+		NewGaugeFunc("process_start_time_seconds", "Start time of the process since the Unix epoch, in seconds.", func() float64 {
+			return start
+		}),
+	)
+}
+
+// memStats caches runtime.MemStats for a second, so a scrape only stops the
+// world once.
+type memStats struct {
+	mu     sync.Mutex
+	stats  runtime.MemStats
+	readAt time.Time
+}
+
+func (m *memStats) get() *runtime.MemStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if time.Since(m.readAt) > time.Second {
+		runtime.ReadMemStats(&m.stats)
+		m.readAt = time.Now()
+	}
+	return &m.stats
+}
+
+// StatusClass returns the class of an HTTP status code, e.g. "2xx".
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return string(rune('0'+status/100)) + "xx"
+}
@@ -0,0 +1,185 @@
+// Package metrics implements the few Prometheus metric types gomux1 needs and
+// exposes them in the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the Content-Type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the default latency histogram buckets, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector is a metric family that can be registered with a Registry.
+type Collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metric families to expose.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]Collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]Collector{}}
+}
+
+// Register adds collectors to the registry. It panics if a metric of the same
+// name is already registered.
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range collectors {
+		if _, ok := r.collectors[c.name()]; ok {
+			panic("metrics: duplicate metric " + c.name())
+		}
+		r.collectors[c.name()] = c
+	}
+}
+
+// WriteTo writes all metrics, ordered by name, in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]Collector, len(names))
+	for i, name := range names {
+		collectors[i] = r.collectors[name]
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler returns a handler serving the registry's metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// desc describes a metric family.
+type desc struct {
+	metricName string
+	help       string
+	typ        string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, d.typ)
+}
+
+// labelPairs formats the label set of values, plus an optional extra label.
+func (d *desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(d.labels)+1)
+	for i, label := range d.labels {
+		pairs = append(pairs, label+`="`+escapeLabelValue(values[i])+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabelValue(extra[1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// series holds the children of a vector metric, keyed by their label values.
+type series[T any] struct {
+	mu       sync.Mutex
+	children map[string]*child[T]
+}
+
+type child[T any] struct {
+	values []string
+	metric *T
+}
+
+// get returns the child for values, creating it with newMetric if needed.
+func (s *series[T]) get(d *desc, values []string, newMetric func() *T) *T {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.children == nil {
+		s.children = map[string]*child[T]{}
+	}
+	c, ok := s.children[key]
+	if !ok {
+		c = &child[T]{values: append([]string(nil), values...), metric: newMetric()}
+		s.children[key] = c
+	}
+	return c.metric
+}
+
+// sorted returns the children ordered by their label values.
+func (s *series[T]) sorted() []*child[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	children := make([]*child[T], 0, len(s.children))
+	for _, c := range s.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Join(children[i].values, "\xff") < strings.Join(children[j].values, "\xff")
+	})
+	return children
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	requests := NewCounterVec("test_requests_total", "Requests.", "route", "status_class")
+	inFlight := NewGaugeVec("test_in_flight", "In flight.")
+	latency := NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.5, 0.1}, "route")
+	r.Register(requests, inFlight, latency, NewGaugeFunc("test_answer", "The answer.", func() float64 { return 42 }))
+
+	requests.With("/v1/ping", "2xx").Inc()
+	requests.With("/v1/ping", "2xx").Add(2)
+	requests.With(`/v1/"quoted"`, "5xx").Inc()
+	inFlight.With().Inc()
+	inFlight.With().Inc()
+	inFlight.With().Dec()
+	latency.With("/v1/ping").Observe(0.05)
+	latency.With("/v1/ping").Observe(0.1)
+	latency.With("/v1/ping").Observe(3)
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_answer The answer.
+# TYPE test_answer gauge
+test_answer 42
+# HELP test_in_flight In flight.
+# TYPE test_in_flight gauge
+test_in_flight 1
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/v1/ping",le="0.1"} 2
+test_latency_seconds_bucket{route="/v1/ping",le="0.5"} 2
+test_latency_seconds_bucket{route="/v1/ping",le="+Inf"} 3
+test_latency_seconds_sum{route="/v1/ping"} 3.15
+test_latency_seconds_count{route="/v1/ping"} 3
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/v1/\"quoted\"",status_class="5xx"} 1
+test_requests_total{route="/v1/ping",status_class="2xx"} 3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("wrong exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	Default.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rr.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("wrong Content-Type: %v", got)
+	}
+	for _, metric := range []string{"# TYPE http_requests_total counter", "go_goroutines ", "go_info{version=", "process_start_time_seconds "} {
+		if !strings.Contains(rr.Body.String(), metric) {
+			t.Errorf("%q is missing from:\n%s", metric, rr.Body.String())
+		}
+	}
+}
+
+func TestDuplicateRegistration(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("duplicate registration didn't panic")
+		}
+	}()
+	r := NewRegistry()
+	r.Register(NewCounterVec("dup_total", "Dup."), NewGaugeVec("dup_total", "Dup."))
+}
+
+func TestStatusClass(t *testing.T) {
+	t.Parallel()
+	for status, want := range map[int]string{200: "2xx", 308: "3xx", 404: "4xx", 503: "5xx", 0: "unknown"} {
+		if got := StatusClass(status); got != want {
+			t.Errorf("StatusClass(%d): got %v want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a value that only goes up.
+type Counter struct {
+	bits atomic.Uint64
+}
+
+// Inc adds 1 to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v (which must not be negative) to the counter.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counters can't decrease")
+	}
+	addFloat(&c.bits, v)
+}
+
+// Value returns the counter's value.
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Inc adds 1 to the gauge.
+func (g *Gauge) Inc() {
+	addFloat(&g.bits, 1)
+}
+
+// Dec subtracts 1 from the gauge.
+func (g *Gauge) Dec() {
+	addFloat(&g.bits, -1)
+}
+
+// Value returns the gauge's value.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64 // Upper bounds, ascending
+	counts  []uint64  // Per bucket, not cumulative
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// CounterVec is a family of counters partitioned by labels.
+type CounterVec struct {
+	desc
+	series[Counter]
+}
+
+// NewCounterVec returns a counter family with the given label names.
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{desc: desc{metricName: name, help: help, typ: "counter", labels: labels}}
+}
+
+// With returns the counter for the given label values, in label order.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.get(&v.desc, values, func() *Counter { return &Counter{} })
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	for _, c := range v.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(c.values), formatFloat(c.metric.Value()))
+	}
+}
+
+// GaugeVec is a family of gauges partitioned by labels.
+type GaugeVec struct {
+	desc
+	series[Gauge]
+}
+
+// NewGaugeVec returns a gauge family with the given label names.
+func NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{desc: desc{metricName: name, help: help, typ: "gauge", labels: labels}}
+}
+
+// With returns the gauge for the given label values, in label order.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.get(&v.desc, values, func() *Gauge { return &Gauge{} })
+}
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	for _, c := range v.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(c.values), formatFloat(c.metric.Value()))
+	}
+}
+
+// HistogramVec is a family of histograms partitioned by labels.
+type HistogramVec struct {
+	desc
+	series[Histogram]
+	buckets []float64
+}
+
+// NewHistogramVec returns a histogram family with the given bucket upper
+// bounds (DefaultBuckets if nil) and label names.
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{desc: desc{metricName: name, help: help, typ: "histogram", labels: labels}, buckets: buckets}
+}
+
+// With returns the histogram for the given label values, in label order.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.get(&v.desc, values, func() *Histogram { return newHistogram(v.buckets) })
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	for _, c := range v.sorted() {
+		h := c.metric
+		h.mu.Lock()
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, v.labelPairs(c.values, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, v.labelPairs(c.values, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.metricName, v.labelPairs(c.values), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.metricName, v.labelPairs(c.values), h.count)
+		h.mu.Unlock()
+	}
+}
+
+// GaugeFunc is a gauge whose value is read from a function at collection time.
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc returns a gauge reporting fn's value.
+func NewGaugeFunc(name string, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{desc: desc{metricName: name, help: help, typ: "gauge"}, fn: fn}
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.fn()))
+}
+
+// CounterFunc is a counter whose value is read from a function at collection time.
+type CounterFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewCounterFunc returns a counter reporting fn's value, which must never decrease.
+func NewCounterFunc(name string, help string, fn func() float64) *CounterFunc {
+	return &CounterFunc{desc: desc{metricName: name, help: help, typ: "counter"}, fn: fn}
+}
+
+func (c *CounterFunc) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", c.metricName, formatFloat(c.fn()))
+}
@@ -0,0 +1,143 @@
+// Package ratelimit implements per-client token bucket rate limits.
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter allows each client, identified by a key, rate requests per second
+// with bursts of up to burst requests. Buckets of clients that have been idle
+// for the idle timeout are forgotten, and past the max number of buckets the
+// least recently used one is, so memory is bounded either way.
+type Limiter struct {
+	rate       float64
+	burst      int
+	idle       time.Duration
+	maxBuckets int
+	clock      func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     list.List // Of *bucket, the most recently used first
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time // When tokens was last updated
+}
+
+// Result is the outcome of a request checked by Allow.
+type Result struct {
+	Allowed    bool
+	Limit      int           // The burst size
+	Remaining  int           // Requests the client can make right away
+	Reset      time.Duration // Until the client's bucket is full again
+	RetryAfter time.Duration // Until the next request will be allowed, if this one wasn't
+}
+
+// New returns a Limiter forgetting buckets idle for idleTimeout, which is
+// raised to the time a bucket takes to fill up if it's shorter, and keeping
+// at most maxBuckets buckets (0 for no limit).
+func New(rate float64, burst int, idleTimeout time.Duration, maxBuckets int) *Limiter {
+	if fill := time.Duration(float64(burst) / rate * float64(time.Second)); idleTimeout < fill {
+		// An idle bucket is only equivalent to a new one once it's full
+		idleTimeout = fill
+	}
+	return &Limiter{
+		rate:       rate,
+		burst:      burst,
+		idle:       idleTimeout,
+		maxBuckets: maxBuckets,
+		clock:      time.Now,
+		buckets:    map[string]*list.Element{},
+	}
+}
+
+// Allow takes a token from the bucket of the client key, if there's one left.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock()
+	l.sweep(now)
+	return l.take(l.bucket(key, now), now)
+}
+
+// AllowVia is like Allow, except that a client key without a bucket yet
+// first takes a token from the bucket of the client via, and only gets a
+// bucket of its own if that's allowed. Keys the client can pick freely, such
+// as unverified credentials, can then be scoped to a client it can't, such as
+// its IP address: picking a new key for each request doesn't get around the
+// limit, as new keys are limited like via is.
+func (l *Limiter) AllowVia(key string, via string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock()
+	l.sweep(now)
+	if _, ok := l.buckets[key]; !ok {
+		if result := l.take(l.bucket(via, now), now); !result.Allowed {
+			return result
+		}
+	}
+	return l.take(l.bucket(key, now), now)
+}
+
+// Len returns the number of buckets being tracked.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// bucket returns the bucket of key, creating a full one if there's none, and
+// marks it as the most recently used.
+func (l *Limiter) bucket(key string, now time.Time) *bucket {
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		return e.Value.(*bucket)
+	}
+	if l.maxBuckets > 0 && len(l.buckets) >= l.maxBuckets {
+		l.remove(l.lru.Back())
+	}
+	b := &bucket{key: key, tokens: float64(l.burst), last: now}
+	l.buckets[key] = l.lru.PushFront(b)
+	return b
+}
+
+// take takes a token from b, if there's one left.
+func (l *Limiter) take(b *bucket, now time.Time) Result {
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	result := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.burst) - b.tokens)
+	return result
+}
+
+// sweep forgets idle buckets. They're the least recently used, so it only
+// looks at as many buckets as it forgets, plus one.
+func (l *Limiter) sweep(now time.Time) {
+	for e := l.lru.Back(); e != nil && now.Sub(e.Value.(*bucket).last) >= l.idle; e = l.lru.Back() {
+		l.remove(e)
+	}
+}
+
+func (l *Limiter) remove(e *list.Element) {
+	l.lru.Remove(e)
+	delete(l.buckets, e.Value.(*bucket).key)
+}
+
+// duration returns how long it takes to get tokens back.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(2, 3, time.Minute, 0)
+	l.clock = func() time.Time { return now }
+
+	// The burst is allowed right away
+	for i := 2; i >= 0; i-- {
+		r := l.Allow("a")
+		if !r.Allowed || r.Remaining != i || r.Limit != 3 {
+			t.Fatalf("request %d: wrong result %+v", 3-i, r)
+		}
+	}
+	r := l.Allow("a")
+	if r.Allowed || r.RetryAfter != 500*time.Millisecond || r.Reset != 1500*time.Millisecond {
+		t.Errorf("wrong result when over the limit: %+v", r)
+	}
+
+	// Other clients have their own bucket
+	if r := l.Allow("b"); !r.Allowed {
+		t.Errorf("client b was limited: %+v", r)
+	}
+
+	// Tokens come back at the rate
+	now = now.Add(500 * time.Millisecond)
+	if r := l.Allow("a"); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("wrong result after refill: %+v", r)
+	}
+	if r := l.Allow("a"); r.Allowed {
+		t.Errorf("allowed more than the refilled tokens: %+v", r)
+	}
+
+	// Buckets never hold more than the burst
+	now = now.Add(time.Hour)
+	if r := l.Allow("a"); r.Remaining != 2 {
+		t.Errorf("wrong result after a long pause: %+v", r)
+	}
+}
+
+func TestIdleBucketsExpire(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(10, 10, time.Minute, 0)
+	l.clock = func() time.Time { return now }
+	for _, key := range []string{"a", "b", "c"} {
+		l.Allow(key)
+	}
+
+	now = now.Add(40 * time.Second)
+	l.Allow("a")
+	if l.Len() != 3 {
+		t.Errorf("buckets expired early: %v", l.Len())
+	}
+	now = now.Add(30 * time.Second)
+	l.Allow("d")
+	if l.Len() != 2 {
+		t.Errorf("want only a and d left, got %v buckets", l.Len())
+	}
+}
+
+func TestMaxBuckets(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(1, 1, time.Minute, 2)
+	l.clock = func() time.Time { return now }
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("a")
+	// b is the least recently used
+	l.Allow("c")
+	if l.Len() != 2 {
+		t.Fatalf("want 2 buckets, got %v", l.Len())
+	}
+	if r := l.Allow("a"); r.Allowed {
+		t.Errorf("a's bucket was forgotten: %+v", r)
+	}
+	if r := l.Allow("b"); !r.Allowed {
+		t.Errorf("b's bucket wasn't forgotten: %+v", r)
+	}
+}
+
+func TestAllowVia(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(1, 2, time.Minute, 0)
+	l.clock = func() time.Time { return now }
+
+	// New keys take a token from ip too
+	for _, key := range []string{"a", "b"} {
+		if r := l.AllowVia(key, "ip"); !r.Allowed || r.Remaining != 1 {
+			t.Errorf("%s: wrong result %+v", key, r)
+		}
+	}
+	if r := l.AllowVia("c", "ip"); r.Allowed || r.RetryAfter != time.Second {
+		t.Errorf("a new key was allowed over ip's limit: %+v", r)
+	}
+	if l.Len() != 3 {
+		t.Errorf("want buckets for a, b and ip only, got %v", l.Len())
+	}
+	// Known keys only use their own bucket
+	if r := l.AllowVia("a", "ip"); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("a known key was limited by ip: %+v", r)
+	}
+}
+
+func TestIdleTimeoutCoversFill(t *testing.T) {
+	t.Parallel()
+	// A bucket taking 100s to fill up mustn't be forgotten after 1s, which would reset it
+	l := New(0.1, 10, time.Second, 0)
+	if l.idle != 100*time.Second {
+		t.Errorf("wrong idle timeout: %v", l.idle)
+	}
+}
@@ -0,0 +1,66 @@
+// Package requestid carries the ID of the request being served through its
+// context.Context, so it can be returned to the client and included in logs.
+package requestid
+
+import (
+	"context"
+	"net/http"
+)
+
+// Header is the request/response header carrying the request ID.
+const Header = "X-Request-ID"
+
+// maxLength is the longest incoming request ID we'll accept as is.
+const maxLength = 128
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the request ID id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware accepts the request ID passed in the X-Request-ID header (e.g. by
+// our ingress) or generates one with newID, stores it in the request's context
+// and echoes it in the X-Request-ID response header. Requests that already
+// carry an ID, from an outer Middleware, are passed through as is.
+func Middleware(newID func() string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if FromContext(r.Context()) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id := r.Header.Get(Header)
+			if !valid(id) {
+				id = newID()
+			}
+			w.Header().Set(Header, id)
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+		})
+	}
+}
+
+// valid reports whether an incoming request ID is safe to use as is. Only IDs
+// made of letters, digits and a few separators are accepted so they can't be
+// used to inject anything into our headers or logs.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{name: "Incoming ID is kept", incoming: "abc-123_x.y:z", want: "abc-123_x.y:z"},
+		{name: "Missing ID is generated", incoming: "", want: "generated"},
+		{name: "Unsafe ID is replaced", incoming: "abc\nfake log line", want: "generated"},
+		{name: "Overlong ID is replaced", incoming: string(make([]byte, maxLength+1)), want: "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := Middleware(func() string { return "generated" })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(Header, tt.incoming)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if seen != tt.want {
+				t.Errorf("request ID in context: got %q want %q", seen, tt.want)
+			}
+			if got := rr.Header().Get(Header); got != tt.want {
+				t.Errorf("request ID in response header: got %q want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rakhbari/gomux1/accesslog"
+)
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	s := newTestServer(t, WithAccessLog(&buf), WithIDGenerator(func() string { return "req-1" }))
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+
+	for _, path := range []string{"/v1/ping", "/missing"} {
+		resp, err := http.Get("http://" + s.Addr("HTTP") + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	cancel()
+	<-ran
+
+	var entries []accesslog.Entry
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry accesslog.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid access log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("want 2 access log lines, got %d", len(entries))
+	}
+	ping, missing := entries[0], entries[1]
+	if ping.Route != "/v1/ping" || ping.Status != http.StatusOK || ping.RequestId != "req-1" || ping.Bytes == 0 || ping.RemoteIp != "127.0.0.1" {
+		t.Errorf("wrong ping entry: %+v", ping)
+	}
+	if missing.Route != "" || missing.Path != "/missing" || missing.Status != http.StatusNotFound {
+		t.Errorf("wrong missing entry: %+v", missing)
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rakhbari/gomux1/apierror"
+	"github.com/rakhbari/gomux1/logging"
+	utils "github.com/rakhbari/gomux1/utils"
+)
+
+// DumpSignals make the app log a dump of its goroutines' stacks.
+var DumpSignals = []os.Signal{syscall.SIGUSR1}
+
+func isDumpSignal(sig os.Signal) bool {
+	for _, s := range DumpSignals {
+		if sig == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RuntimePayload is the /debug/runtime payload.
+type RuntimePayload struct {
+	Goroutines int           `json:"goroutines"`
+	GoMaxProcs int           `json:"gomaxprocs"`
+	NumCPU     int           `json:"numCpu"`
+	OpenFDs    int           `json:"openFds"` // -1 where /proc/self/fd isn't available
+	Memory     MemoryStats   `json:"memory"`
+	GC         GCStats       `json:"gc"`
+	Build      utils.Version `json:"build"`
+}
+
+// MemoryStats are the memory stats of RuntimePayload, from runtime.MemStats.
+type MemoryStats struct {
+	HeapAllocBytes  uint64 `json:"heapAllocBytes"`
+	HeapInuseBytes  uint64 `json:"heapInuseBytes"`
+	HeapObjects     uint64 `json:"heapObjects"`
+	StackInuseBytes uint64 `json:"stackInuseBytes"`
+	SysBytes        uint64 `json:"sysBytes"`
+	TotalAllocBytes uint64 `json:"totalAllocBytes"`
+	Mallocs         uint64 `json:"mallocs"`
+	Frees           uint64 `json:"frees"`
+}
+
+// GCStats are the garbage collector stats of RuntimePayload.
+type GCStats struct {
+	NumGC          uint32    `json:"numGc"`
+	LastGC         time.Time `json:"lastGc"`
+	NextGCBytes    uint64    `json:"nextGcBytes"`
+	PauseTotalMs   float64   `json:"pauseTotalMs"`
+	RecentPausesMs []float64 `json:"recentPausesMs"` // Up to the last 16 pauses, most recent first
+	CPUFraction    float64   `json:"cpuFraction"`
+}
+
+// mountDebugRoutes mounts net/http/pprof and the runtime diagnostics under
+// /debug, guarded by DEBUG_TOKEN if it's set.
+func (s *Server) mountDebugRoutes(router *mux.Router) {
+	debug := router.PathPrefix("/debug").Subrouter()
+	debug.Use(s.debugAuth)
+	debug.HandleFunc("/runtime", s.RuntimeHandler).Methods("GET")
+	debug.HandleFunc("/goroutines", s.GoroutinesHandler).Methods("GET")
+	debug.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	debug.HandleFunc("/pprof/profile", pprof.Profile)
+	debug.HandleFunc("/pprof/symbol", pprof.Symbol)
+	debug.HandleFunc("/pprof/trace", pprof.Trace)
+	// The index also serves the named profiles, e.g. /debug/pprof/heap
+	debug.PathPrefix("/pprof/").HandlerFunc(pprof.Index)
+}
+
+// debugAuth requires the DEBUG_TOKEN bearer token, if it's set.
+func (s *Server) debugAuth(next http.Handler) http.Handler {
+	token := s.cfg.Debug.Token
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="debug"`)
+			s.ErrorResponseWriter(w, r, apierror.Unauthorized.New("A valid bearer token is required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RuntimeHandler responds with the Go runtime's stats and the app's build info.
+func (s *Server) RuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	gc := GCStats{
+		NumGC:          ms.NumGC,
+		NextGCBytes:    ms.NextGC,
+		PauseTotalMs:   float64(ms.PauseTotalNs) / 1e6,
+		RecentPausesMs: []float64{},
+		CPUFraction:    ms.GCCPUFraction,
+	}
+	if ms.LastGC > 0 {
+		gc.LastGC = time.Unix(0, int64(ms.LastGC)).UTC()
+	}
+	// PauseNs is a circular buffer with the most recent pause at (NumGC+255)%256
+	for i := uint32(0); i < ms.NumGC && i < 16; i++ {
+		gc.RecentPausesMs = append(gc.RecentPausesMs, float64(ms.PauseNs[(ms.NumGC-1-i)%256])/1e6)
+	}
+	build := s.version()
+	build.Deps = nil
+	payload := RuntimePayload{
+		Goroutines: runtime.NumGoroutine(),
+		GoMaxProcs: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		OpenFDs:    openFDs(),
+		Memory: MemoryStats{
+			HeapAllocBytes:  ms.HeapAlloc,
+			HeapInuseBytes:  ms.HeapInuse,
+			HeapObjects:     ms.HeapObjects,
+			StackInuseBytes: ms.StackInuse,
+			SysBytes:        ms.Sys,
+			TotalAllocBytes: ms.TotalAlloc,
+			Mallocs:         ms.Mallocs,
+			Frees:           ms.Frees,
+		},
+		GC:    gc,
+		Build: build,
+	}
+	s.HttpResponseWriter(w, r, http.StatusOK, &StandardApiResponse{Payload: payload})
+}
+
+// GoroutinesHandler responds with a plain text dump of all goroutines' stacks.
+func (s *Server) GoroutinesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// logGoroutines logs a dump of all goroutines' stacks.
+func (s *Server) logGoroutines() {
+	var buf bytes.Buffer
+	runtimepprof.Lookup("goroutine").WriteTo(&buf, 2)
+	s.logger.Info("Goroutine dump", "goroutines", runtime.NumGoroutine(), logging.KeyStack, buf.String())
+}
+
+// openFDs returns the number of open file descriptors of the process, or -1
+// if it can't be told.
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	// Less the one ReadDir had open
+	return len(entries) - 1
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rakhbari/gomux1/config"
+	utils "github.com/rakhbari/gomux1/utils"
+)
+
+func TestDebugRoutes(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, WithVersionSource(func() utils.Version { return utils.Version{GitSha: "abc123"} }))
+	router, err := s.NewRouter(config.RoutesDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	rr := get("/debug/runtime")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wrong /debug/runtime status: %v", rr.Code)
+	}
+	var resp struct {
+		Payload RuntimePayload `json:"payload"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("want an envelope, got %q: %v", rr.Body.String(), err)
+	}
+	payload := resp.Payload
+	if payload.Goroutines == 0 || payload.GoMaxProcs == 0 || payload.Memory.SysBytes == 0 || payload.Build.GitSha != "abc123" {
+		t.Errorf("wrong payload: %+v", payload)
+	}
+	if payload.OpenFDs == 0 {
+		t.Errorf("wrong open FDs: %v", payload.OpenFDs)
+	}
+
+	if rr := get("/debug/goroutines"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine ") {
+		t.Errorf("wrong goroutine dump: %v %q", rr.Code, rr.Body.String())
+	}
+	for _, target := range []string{"/debug/pprof/", "/debug/pprof/heap?debug=1", "/debug/pprof/cmdline"} {
+		if rr := get(target); rr.Code != http.StatusOK {
+			t.Errorf("%s: wrong status %v", target, rr.Code)
+		}
+	}
+
+	// The debug routes aren't mounted by default
+	rr = httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/debug/runtime", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("debug routes mounted by default: %v", rr.Code)
+	}
+}
+
+func TestDebugToken(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Debug.Token = "secret"
+	router, err := s.NewRouter(config.RoutesDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		auth string
+		want int
+	}{
+		{auth: "", want: http.StatusUnauthorized},
+		{auth: "Bearer wrong", want: http.StatusUnauthorized},
+		{auth: "Basic c2VjcmV0", want: http.StatusUnauthorized},
+		{auth: "Bearer secret", want: http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/debug/pprof/", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%q: wrong status: got %v want %v", tt.auth, rr.Code, tt.want)
+		}
+		if tt.want == http.StatusUnauthorized {
+			resp := ExpectedHttpResponse{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || len(resp.Errors) != 1 || resp.Errors[0].Code != "E0005" {
+				t.Errorf("%q: want an E0005 envelope, got %q", tt.auth, rr.Body.String())
+			}
+			if rr.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%q: missing WWW-Authenticate header", tt.auth)
+			}
+		}
+	}
+}
+
+func TestCoordinatorDumpSignal(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	s := newTestServer(t, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	s.manager.Add("HTTP", s.configureAppServer("127.0.0.1:0", s.Router()))
+	if err := s.manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newCoordinator(s, 0, time.Second)
+	c.exit = func(code int) { t.Errorf("unexpected forced exit with code %d", code) }
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan error, 1)
+	go func() {
+		waited <- c.Wait(ctx)
+	}()
+
+	// A dump signal doesn't stop the server
+	c.signals <- syscall.SIGUSR1
+	time.Sleep(50 * time.Millisecond)
+	if !s.ready.Load() {
+		t.Error("a dump signal started a shutdown")
+	}
+	cancel()
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Wait() returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait() didn't return")
+	}
+
+	dec := json.NewDecoder(&buf)
+	for {
+		var entry map[string]any
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("no goroutine dump logged: %v", err)
+		}
+		if entry["msg"] == "Goroutine dump" {
+			if stack, _ := entry["stack"].(string); !strings.Contains(stack, "goroutine ") {
+				t.Errorf("wrong log entry: %v", entry)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/rakhbari/gomux1/apierror"
+)
+
+func TestErrorResponseWriter(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	notFound := k8serrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "user1-token")
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{name: "catalog error", err: apierror.BadRequest.New("namespace is required"), status: http.StatusBadRequest, code: "E0006", detail: "namespace is required"},
+		{name: "k8s not found", err: apierror.SvcAcctToken.Wrap(fmt.Errorf("get: %w", notFound)), status: http.StatusNotFound, code: "E0003", detail: `secrets "user1-token" not found`},
+		{name: "unknown error", err: errors.New("boom"), status: http.StatusInternalServerError, code: "E0000", detail: "boom"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		s.ErrorResponseWriter(rr, httptest.NewRequest("GET", "/", nil), tt.err)
+		if rr.Code != tt.status {
+			t.Errorf("%s: wrong status: got %v want %v", tt.name, rr.Code, tt.status)
+		}
+		resp := ExpectedHttpResponse{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0].Code != tt.code || resp.Errors[0].Detail != tt.detail || resp.Errors[0].HelpUrl == "" {
+			t.Errorf("%s: wrong errors: %+v", tt.name, resp.Errors)
+		}
+	}
+}
+
+func TestBearerTokenFormHandlerValidation(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	req := httptest.NewRequest("POST", "/v1/bearer-token", strings.NewReader(url.Values{"namespace": {"app1"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("wrong status: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestErrorCatalogHandler(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/errors", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wrong status: got %v want %v", rr.Code, http.StatusOK)
+	}
+	resp := struct {
+		Payload ErrorCatalogPayload `json:"payload"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(resp.Payload.Errors), len(apierror.Catalog()); got != want || got == 0 {
+		t.Fatalf("wrong number of entries: got %d want %d", got, want)
+	}
+	first := resp.Payload.Errors[0]
+	if first != *apierror.Internal {
+		t.Errorf("wrong first entry: got %+v want %+v", first, *apierror.Internal)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rakhbari/gomux1/bind"
+)
+
+// Handle adapts a typed handler function to an http.HandlerFunc. The request
+// is bound to a Req struct (see package bind for its path, query, form, json
+// and validation tags) and fn's Resp is written as the payload of a 200
+// envelope. Binding errors are written as 400s with an error per invalid
+// field (or a 413 if the body is larger than SERVER_MAX_BODY_BYTES), and
+// errors returned by fn as mapped by ErrorResponseWriter. It panics if Req's
+// validation tags are invalid (see bind.CheckTags).
+func Handle[Req, Resp any](s *Server, fn func(ctx context.Context, req Req) (Resp, error)) http.HandlerFunc {
+	var zero Req
+	if err := bind.CheckTags(&zero); err != nil {
+		panic(err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := s.bindRequest(w, r, &req); err != nil {
+			s.ErrorResponseWriter(w, r, err)
+			return
+		}
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			s.ErrorResponseWriter(w, r, err)
+			return
+		}
+		s.HttpResponseWriter(w, r, http.StatusOK, &StandardApiResponse{Payload: resp})
+	}
+}
+
+// bindRequest binds r to dst (see bind.Request), reading at most
+// SERVER_MAX_BODY_BYTES of its body.
+func (s *Server) bindRequest(w http.ResponseWriter, r *http.Request, dst any) error {
+	if limit := s.cfg.Server.MaxBodyBytes; limit > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(limit))
+	}
+	return bind.Request(r, mux.Vars(r), dst)
+}
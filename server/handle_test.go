@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rakhbari/gomux1/apierror"
+)
+
+type greetRequest struct {
+	Namespace string `path:"namespace" validate:"required,dns1123label"`
+	Name      string `query:"name" validate:"required" pattern:"^[A-Za-z]+$"`
+}
+
+type greetPayload struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	router := mux.NewRouter()
+	router.Handle("/v1/{namespace}/greet", Handle(s, func(ctx context.Context, req greetRequest) (greetPayload, error) {
+		if req.Name == "Nobody" {
+			return greetPayload{}, apierror.NotFound.New("no one to greet")
+		}
+		return greetPayload{Greeting: "Hello " + req.Name + " from " + req.Namespace}, nil
+	}))
+
+	tests := []struct {
+		name     string
+		url      string
+		status   int
+		greeting string
+		fields   []string
+	}{
+		{name: "valid", url: "/v1/app1/greet?name=Ann", status: http.StatusOK, greeting: "Hello Ann from app1"},
+		{name: "handler error", url: "/v1/app1/greet?name=Nobody", status: http.StatusNotFound},
+		{name: "invalid fields", url: "/v1/App_1/greet?name=A1", status: http.StatusBadRequest, fields: []string{"namespace", "name"}},
+		{name: "missing field", url: "/v1/app1/greet", status: http.StatusBadRequest, fields: []string{"name"}},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+		if rr.Code != tt.status {
+			t.Errorf("%s: wrong status: got %v want %v", tt.name, rr.Code, tt.status)
+		}
+		resp := struct {
+			Payload *greetPayload `json:"payload"`
+			Errors  []Error       `json:"errors"`
+		}{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if tt.greeting != "" && (resp.Payload == nil || resp.Payload.Greeting != tt.greeting) {
+			t.Errorf("%s: wrong payload: %+v", tt.name, resp.Payload)
+		}
+		if tt.fields == nil {
+			continue
+		}
+		if len(resp.Errors) != len(tt.fields) {
+			t.Errorf("%s: want an error per invalid field %v, got %+v", tt.name, tt.fields, resp.Errors)
+			continue
+		}
+		for i, e := range resp.Errors {
+			if e.Code != apierror.InvalidField.Code || e.Field != tt.fields[i] || e.Detail == "" {
+				t.Errorf("%s: wrong error for %s: %+v", tt.name, tt.fields[i], e)
+			}
+		}
+	}
+}
+
+func TestHandleInvalidTags(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		if recover() == nil {
+			t.Error("Handle didn't panic on an invalid pattern")
+		}
+	}()
+	Handle(newTestServer(t), func(ctx context.Context, req struct {
+		Name string `query:"name" pattern:"(unclosed"`
+	}) (greetPayload, error) {
+		return greetPayload{}, nil
+	})
+}
+
+func TestHandleBodyTooLarge(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.MaxBodyBytes = 16
+	handler := Handle(s, func(ctx context.Context, req struct {
+		Name string `json:"name"`
+	}) (greetPayload, error) {
+		return greetPayload{Greeting: "Hello " + req.Name}, nil
+	})
+
+	for body, status := range map[string]int{`{"name":"Ann"}`: http.StatusOK, `{"name":"Annabelle Smith"}`: http.StatusRequestEntityTooLarge} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if rr.Code != status {
+			t.Errorf("%s: wrong status: got %v want %v", body, rr.Code, status)
+		}
+		if status == http.StatusRequestEntityTooLarge && !strings.Contains(rr.Body.String(), apierror.PayloadTooLarge.Code) {
+			t.Errorf("%s: want an %s error, got %s", body, apierror.PayloadTooLarge.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestBearerTokenFormFieldErrors(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	form := url.Values{"namespace": {"App1"}, "argo_base_url": {"argo"}}
+	req := httptest.NewRequest("POST", "/v1/bearer-token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	resp := ExpectedHttpResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]bool{}
+	for _, e := range resp.Errors {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{"namespace", "service_acct", "argo_base_url"} {
+		if !fields[field] {
+			t.Errorf("no error for %s: %+v", field, resp.Errors)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rakhbari/gomux1/apierror"
+	"github.com/rakhbari/gomux1/health"
+	utils "github.com/rakhbari/gomux1/utils"
+)
+
+type PingPayload struct {
+	Response string `json:"response"`
+}
+
+// BearerTokenRequest is the form posted to /v1/bearer-token.
+type BearerTokenRequest struct {
+	Namespace   string `form:"namespace" validate:"required,dns1123label"`
+	SvcAcct     string `form:"service_acct" validate:"required,dns1123subdomain"`
+	ArgoBaseUrl string `form:"argo_base_url" validate:"required,url"`
+}
+
+type ErrorCatalogPayload struct {
+	Errors []apierror.Definition `json:"errors"`
+}
+
+func (s *Server) PingHandler(w http.ResponseWriter, r *http.Request) {
+	s.pingHandler(w, r)
+}
+
+func (s *Server) ping(ctx context.Context, _ struct{}) (PingPayload, error) {
+	// Just respond with a "pong!"
+	return PingPayload{Response: "pong!"}, nil
+}
+
+// HealthCheckHandler reports the results of all health checks (see Health).
+// Once a shutdown has started we report unhealthy so no new traffic is routed to us.
+func (s *Server) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	s.probeHandler("")(w, r)
+}
+
+// probeHandler returns the handler of a health probe, responding with the
+// latest results of the checks affecting it: 200 if it passes (or only warns)
+// and 503 if a critical check is failing.
+func (s *Server) probeHandler(probe health.Probe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := s.health.Report(r.Context(), probe)
+		status := http.StatusOK
+		if !report.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		s.HttpResponseWriter(w, r, status, &StandardApiResponse{Payload: report})
+	}
+}
+
+// VersionHandler responds with the app's build info and uptime. The modules it
+// was built with are only listed with ?deps=true.
+func (s *Server) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	responseStatus := http.StatusOK
+	version := s.version()
+	// If no build info could be resolved for some reason set responseStatus to NotFound
+	if version.IsZero() {
+		responseStatus = http.StatusNotFound
+	}
+	if !version.StartTime.IsZero() {
+		version.Uptime = s.clock().Sub(version.StartTime).Truncate(time.Second).String()
+	}
+	// The dependency list is long, so only include it when asked to
+	if r.URL.Query().Get("deps") != "true" {
+		version.Deps = nil
+	}
+	// Responds with the value of the utils.Version struct loaded at app startup
+	s.HttpResponseWriter(w, r, responseStatus, &StandardApiResponse{Payload: &version})
+}
+
+func (s *Server) BearerTokenFormHandler(w http.ResponseWriter, r *http.Request) {
+	var req BearerTokenRequest
+	if err := s.bindRequest(w, r, &req); err != nil {
+		s.ErrorResponseWriter(w, r, err)
+		return
+	}
+
+	kubeConfigPath, err := s.kubeConfigPath()
+	if err != nil {
+		s.ErrorResponseWriter(w, r, apierror.SvcAcctToken.Wrap(err))
+		return
+	}
+	bearerToken, err := utils.GetSvcAcctToken(r.Context(), kubeConfigPath, req.Namespace, req.SvcAcct)
+	if err != nil {
+		s.ErrorResponseWriter(w, r, apierror.SvcAcctToken.Wrap(err))
+		return
+	}
+
+	// https://argo.akhbari.us:9443/workflows/app1?limit=50
+	argoUrl := req.ArgoBaseUrl + "/workflows/" + req.Namespace + "?limit=50"
+	r.Header.Add("Authorization", "Bearer "+*bearerToken)
+	http.Redirect(w, r, argoUrl, http.StatusSeeOther)
+}
+
+// ErrorCatalogHandler lists the catalog of errors the API can return.
+func (s *Server) ErrorCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	s.HttpResponseWriter(w, r, http.StatusOK, &StandardApiResponse{Payload: ErrorCatalogPayload{Errors: apierror.Catalog()}})
+}
+
+func (s *Server) ServeStatic(router *mux.Router, staticDirectory string) {
+	staticPaths := map[string]string{
+		"/app/":     staticDirectory + "/",
+		"/styles/":  staticDirectory + "/styles/",
+		"/images/":  staticDirectory + "/images/",
+		"/scripts/": staticDirectory + "/scripts/",
+	}
+	for pathName, pathValue := range staticPaths {
+		router.PathPrefix(pathName).Handler(http.StripPrefix(pathName, http.FileServer(http.Dir(pathValue))))
+	}
+}
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rakhbari/gomux1/config"
+	utils "github.com/rakhbari/gomux1/utils"
+)
+
+type ExpectedHttpResponse struct {
+	RequestId string  `json:"requestId"`
+	Timestamp string  `json:"timestamp"`
+	ExecHost  string  `json:"execHost"`
+	Payload   any     `json:"payload"`
+	Errors    []Error `json:"errors"`
+}
+
+// newTestServer returns a Server listening on ephemeral loopback ports, marked ready.
+func newTestServer(t *testing.T, opts ...Option) *Server {
+	cfg := &config.Config{}
+	cfg.Server.Host = "127.0.0.1"
+	opts = append([]Option{WithVersionSource(func() utils.Version { return utils.Version{} })}, opts...)
+	s := New(cfg, opts...)
+	s.ready.Store(true)
+	return s
+}
+
+func TestPingHandler(t *testing.T) {
+	t.Parallel()
+	t.Log("Testing PingHandler ...")
+	router := newTestServer(t).Router()
+	req, err := http.NewRequest("GET", "/v1/ping", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v, was looking for %v",
+			status, http.StatusOK)
+	}
+
+	resp := ExpectedHttpResponse{}
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Errorf("Got error while trying to unmarshal the response. Error: %+v", err)
+	}
+
+	// Check the response structure
+	if resp.RequestId == "" {
+		t.Error("RequestId is empty")
+	}
+	if resp.Timestamp == "" {
+		t.Error("Timestamp is empty")
+	}
+	if resp.ExecHost == "" {
+		t.Error("ExecHost is empty")
+	}
+
+	// Check the payload
+	payload, ok := resp.Payload.(map[string]interface{})
+	if !ok {
+		t.Error("Payload is not a map")
+	}
+	if response, ok := payload["response"].(string); !ok || response != "pong!" {
+		t.Errorf("Expected 'pong!' response, got %v", payload["response"])
+	}
+}
+
+func TestHealthCheckHandler(t *testing.T) {
+	t.Parallel()
+	t.Log("Testing HealthCheckHandler ...")
+	router := newTestServer(t).Router()
+	req, err := http.NewRequest("GET", "/health", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v, was looking for %v",
+			status, http.StatusOK)
+	}
+
+	resp := ExpectedHttpResponse{}
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Errorf("Got error while trying to unmarshal the response. Error: %+v", err)
+	}
+
+	// Check the response structure
+	if resp.RequestId == "" {
+		t.Error("RequestId is empty")
+	}
+	if resp.Timestamp == "" {
+		t.Error("Timestamp is empty")
+	}
+	if resp.ExecHost == "" {
+		t.Error("ExecHost is empty")
+	}
+
+	// Check the payload
+	payload, ok := resp.Payload.(map[string]interface{})
+	if !ok {
+		t.Error("Payload is not a map")
+	}
+	if healthy, ok := payload["healthy"].(bool); !ok || !healthy {
+		t.Errorf("Expected healthy=true, got %v", payload["healthy"])
+	}
+}
+
+func TestVersionUptimeAndDeps(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	version := utils.Version{
+		GitSha:    "abc123",
+		StartTime: start,
+		Deps:      []utils.Dependency{{Path: "github.com/gorilla/mux", Version: "v1.8.0"}},
+	}
+	router := newTestServer(t,
+		WithVersionSource(func() utils.Version { return version }),
+		WithClock(func() time.Time { return start.Add(90*time.Minute + 1500*time.Millisecond) }),
+	).Router()
+	get := func(target string) utils.Version {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		var resp struct {
+			Payload utils.Version `json:"payload"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Payload
+	}
+
+	payload := get("/version")
+	if payload.Uptime != "1h30m1s" || !payload.StartTime.Equal(start) {
+		t.Errorf("wrong uptime: %+v", payload)
+	}
+	if payload.Deps != nil {
+		t.Errorf("deps should only be listed when asked for: %+v", payload.Deps)
+	}
+	if payload := get("/version?deps=true"); len(payload.Deps) != 1 || payload.Deps[0].Path != "github.com/gorilla/mux" {
+		t.Errorf("wrong deps: %+v", payload.Deps)
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		version        utils.Version
+		expectedStatus int
+		expectError    bool
+	}{
+		{
+			name: "Success - Version Available",
+			version: utils.Version{
+				Timestamp: "2024-01-01 12:00:00 UTC",
+				GitSha:    "abc123",
+				GitBranch: "main",
+			},
+			expectedStatus: http.StatusOK,
+			expectError:    false,
+		},
+		{
+			name: "Success - Only Build Info Of A go run Binary",
+			version: utils.Version{
+				Module:    "github.com/rakhbari/gomux1",
+				GoVersion: "go1.22.1",
+			},
+			expectedStatus: http.StatusOK,
+			expectError:    false,
+		},
+		{
+			name:           "Error - Version Not Available",
+			version:        utils.Version{},
+			expectedStatus: http.StatusNotFound,
+			expectError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router := newTestServer(t, WithVersionSource(func() utils.Version { return tt.version })).Router()
+
+			req, err := http.NewRequest("GET", "/version", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.expectedStatus)
+			}
+
+			resp := ExpectedHttpResponse{}
+			err = json.Unmarshal(rr.Body.Bytes(), &resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Check the response structure
+			if resp.RequestId == "" {
+				t.Error("RequestId is empty")
+			}
+			if resp.Timestamp == "" {
+				t.Error("Timestamp is empty")
+			}
+			if resp.ExecHost == "" {
+				t.Error("ExecHost is empty")
+			}
+
+			if !tt.expectError {
+				// Check if we got the expected version in the payload
+				versionPayload, ok := resp.Payload.(map[string]interface{})
+				if !ok {
+					t.Fatal("payload is not a map")
+				}
+				if versionPayload["timestamp"] != tt.version.Timestamp {
+					t.Errorf("handler returned wrong timestamp: got %v want %v",
+						versionPayload["timestamp"], tt.version.Timestamp)
+				}
+				if versionPayload["gitSha"] != tt.version.GitSha {
+					t.Errorf("handler returned wrong gitSha: got %v want %v",
+						versionPayload["gitSha"], tt.version.GitSha)
+				}
+				if versionPayload["gitBranch"] != tt.version.GitBranch {
+					t.Errorf("handler returned wrong gitBranch: got %v want %v",
+						versionPayload["gitBranch"], tt.version.GitBranch)
+				}
+			}
+		})
+	}
+}
+
+func TestInjectedResponseFields(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestServer(t,
+		WithClock(func() time.Time { return now }),
+		WithIDGenerator(func() string { return "req-1" }),
+		WithHostName("test-host"),
+	)
+
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/ping", nil))
+
+	resp := ExpectedHttpResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.RequestId != "req-1" {
+		t.Errorf("wrong requestId: got %v want %v", resp.RequestId, "req-1")
+	}
+	if resp.Timestamp != now.String() {
+		t.Errorf("wrong timestamp: got %v want %v", resp.Timestamp, now.String())
+	}
+	if resp.ExecHost != "test-host" {
+		t.Errorf("wrong execHost: got %v want %v", resp.ExecHost, "test-host")
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, WithIDGenerator(func() string { return "generated" }))
+
+	tests := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{name: "incoming", incoming: "client-id-1", want: "client-id-1"},
+		{name: "missing", incoming: "", want: "generated"},
+		{name: "invalid", incoming: "bad id\n", want: "generated"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/ping", nil)
+		if tt.incoming != "" {
+			req.Header.Set("X-Request-ID", tt.incoming)
+		}
+		rr := httptest.NewRecorder()
+		s.Router().ServeHTTP(rr, req)
+
+		resp := ExpectedHttpResponse{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if got := rr.Header().Get("X-Request-ID"); got != tt.want {
+			t.Errorf("%s: wrong X-Request-ID header: got %v want %v", tt.name, got, tt.want)
+		}
+		if resp.RequestId != tt.want {
+			t.Errorf("%s: wrong requestId: got %v want %v", tt.name, resp.RequestId, tt.want)
+		}
+	}
+}
+
+func TestRequestIDUnmatchedRoutes(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, WithIDGenerator(func() string { return "generated" }))
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	defer func() {
+		cancel()
+		<-ran
+	}()
+
+	// mux only runs its middleware on matched routes, so 404s and 405s must get
+	// their request ID from the handler chain Run builds around the router
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{name: "unknown path", method: "GET", path: "/no-such-path", status: http.StatusNotFound},
+		{name: "wrong method", method: "DELETE", path: "/v1/ping", status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, "http://"+s.Addr("HTTP")+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: wrong status code: got %v want %v", tt.name, resp.StatusCode, tt.status)
+		}
+		if got := resp.Header.Get("X-Request-ID"); got != "generated" {
+			t.Errorf("%s: wrong X-Request-ID header: got %q want %q", tt.name, got, "generated")
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rakhbari/gomux1/config"
+	"github.com/rakhbari/gomux1/health"
+	utils "github.com/rakhbari/gomux1/utils"
+)
+
+// defaultKubeconfigPath is the default of KUBECONFIG_PATH.
+const defaultKubeconfigPath = "~/.kube/config"
+
+// kubeConfigPath is the kubeconfig /v1/bearer-token uses to reach the
+// Kubernetes API: KUBECONFIG_PATH, with a leading ~ expanded to the user's home
+// dir. It's empty if KUBECONFIG_PATH is the default and there's no file there,
+// so the in-cluster config is used. A path that was set explicitly must exist,
+// rather than us quietly using other credentials.
+func (s *Server) kubeConfigPath() (string, error) {
+	kubeConfigPath := s.cfg.Server.KubeconfigPath
+	explicit := kubeConfigPath != defaultKubeconfigPath
+	if rest, ok := strings.CutPrefix(kubeConfigPath, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			if explicit {
+				return "", fmt.Errorf("expanding KUBECONFIG_PATH %s: %w", kubeConfigPath, err)
+			}
+			return "", nil
+		}
+		kubeConfigPath = filepath.Join(home, rest)
+	}
+	if _, err := os.Stat(kubeConfigPath); err != nil {
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("KUBECONFIG_PATH: %w", err)
+		}
+		return "", nil
+	}
+	return kubeConfigPath, nil
+}
+
+// registerHealthChecks registers the built-in checks of the app's dependencies
+// enabled by HEALTH_*. Those of the Kubernetes API and TLS certs
+// only warn, since failing them wouldn't help, while failing the configured
+// TCP, Redis and SQL dependencies takes the app out of rotation. It returns
+// the database the SQL check uses, if any, for Run to close.
+func (s *Server) registerHealthChecks(listeners []config.ListenerConfig) (*sql.DB, error) {
+	cfg := s.cfg.Health
+	register := func(name string, critical bool, check func(ctx context.Context) error) {
+		s.health.Register(health.Check{
+			Name:     name,
+			Critical: critical,
+			Interval: time.Duration(cfg.Interval) * time.Second,
+			Timeout:  time.Duration(cfg.Timeout) * time.Second,
+			Check:    check,
+		})
+	}
+
+	if cfg.Kubernetes {
+		register("kubernetes", false, func(ctx context.Context) error {
+			kubeConfigPath, err := s.kubeConfigPath()
+			if err != nil {
+				return err
+			}
+			return utils.CheckKubernetesApi(ctx, kubeConfigPath)
+		})
+	}
+	if cfg.TlsExpiryDays > 0 {
+		var paths []string
+		seen := map[string]bool{}
+		for _, lc := range listeners {
+			if !lc.Tls {
+				continue
+			}
+			for _, p := range append([]string{lc.TlsCertPath}, lc.TlsCaPaths...) {
+				if !seen[p] {
+					seen[p] = true
+					paths = append(paths, p)
+				}
+			}
+		}
+		if len(paths) > 0 {
+			register("tls-certs", false, health.CertExpiry(paths, time.Duration(cfg.TlsExpiryDays)*24*time.Hour, s.clock))
+		}
+	}
+	dependencies := map[string]func(ctx context.Context) error{}
+	for _, addr := range cfg.TcpAddrs {
+		dependencies["tcp:"+addr] = health.TCP(addr)
+	}
+	for _, addr := range cfg.RedisAddrs {
+		dependencies["redis:"+addr] = health.Redis(addr, cfg.RedisPassword)
+	}
+	for name, check := range dependencies {
+		register(name, true, check)
+	}
+	if cfg.SqlDriver == "" {
+		return nil, nil
+	}
+	db, err := sql.Open(cfg.SqlDriver, cfg.SqlDsn)
+	if err != nil {
+		return nil, err
+	}
+	register("sql", true, health.SQL(db))
+	return db, nil
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rakhbari/gomux1/config"
+	"github.com/rakhbari/gomux1/health"
+)
+
+func TestHealthProbes(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, WithHealthCheck(health.Check{
+		Name:     "db",
+		Critical: true,
+		Instant:  true,
+		Check: func(ctx context.Context) error {
+			return errors.New("connection refused")
+		},
+	}))
+	router := s.Router()
+	probe := func(path string) (int, health.Report) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		var resp struct {
+			Payload health.Report `json:"payload"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: want an envelope, got %q: %v", path, rr.Body.String(), err)
+		}
+		return rr.Code, resp.Payload
+	}
+
+	// A failing critical check fails readiness, but not liveness
+	code, report := probe("/readyz")
+	if code != http.StatusServiceUnavailable || report.Healthy || len(report.Checks) != 2 {
+		t.Fatalf("wrong /readyz response: %v %+v", code, report)
+	}
+	db, server := report.Checks[0], report.Checks[1]
+	if db.Name != "db" || db.Status != health.StatusFail || db.LastError != "connection refused" {
+		t.Errorf("wrong db result: %+v", db)
+	}
+	if server.Name != "server" || server.Status != health.StatusPass {
+		t.Errorf("wrong server result: %+v", server)
+	}
+	if code, report := probe("/livez"); code != http.StatusOK || !report.Healthy {
+		t.Errorf("wrong /livez response: %v %+v", code, report)
+	}
+	if code, _ := probe("/health"); code != http.StatusServiceUnavailable {
+		t.Errorf("wrong /health status: %v", code)
+	}
+
+	// Shutting down fails readiness too
+	s.ready.Store(false)
+	if _, report := probe("/readyz"); report.Checks[1].Status != health.StatusFail {
+		t.Errorf("server check should fail while shutting down: %+v", report.Checks[1])
+	}
+}
+
+func TestBuiltInHealthChecks(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := ln.Addr().String()
+	ln.Close()
+	certPath, keyPath := writeTestCert(t) // Expires in an hour
+	s := newTestServer(t, func(s *Server) {
+		s.cfg.Health.TlsExpiryDays = 14
+		s.cfg.Health.TcpAddrs = []string{closedAddr}
+	})
+	db, err := s.registerHealthChecks([]config.ListenerConfig{
+		{Name: "HTTP", Addr: "127.0.0.1:0"},
+		{Name: "TLS", Addr: "127.0.0.1:0", Tls: true, TlsCertPath: certPath, TlsKeyPath: keyPath},
+	})
+	if err != nil || db != nil {
+		t.Fatalf("registerHealthChecks() = %v, %v", db, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.health.Start(ctx)
+
+	var report health.Report
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		report = s.health.Report(context.Background(), health.Readiness)
+		pending := false
+		for _, result := range report.Checks {
+			pending = pending || result.Status == health.StatusPending
+		}
+		if !pending {
+			break
+		}
+	}
+	results := map[string]health.Result{}
+	for _, result := range report.Checks {
+		results[result.Name] = result
+	}
+	if tcp := results["tcp:"+closedAddr]; tcp.Status != health.StatusFail || !tcp.Critical {
+		t.Errorf("wrong tcp result: %+v", tcp)
+	}
+	if certs := results["tls-certs"]; certs.Status != health.StatusFail || certs.Critical || !strings.Contains(certs.LastError, "expires in 0 days") {
+		t.Errorf("wrong tls-certs result: %+v", certs)
+	}
+	if report.Healthy {
+		t.Errorf("the unreachable TCP dependency should fail readiness: %+v", report)
+	}
+}
+
+func TestSQLHealthCheckUnknownDriver(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.Listeners = `[{"name":"invalid","addr":"127.0.0.1:0"}]`
+	s.cfg.Health.SqlDriver = "unknown"
+	if err := s.Run(context.Background()); ExitCode(err) != ExitConfigError {
+		t.Errorf("want a config error, got %v", err)
+	}
+}
+
+func TestKubeConfigPath(t *testing.T) {
+	t.Parallel()
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	s.cfg.Server.KubeconfigPath = kubeconfig
+	if got, err := s.kubeConfigPath(); err != nil || got != kubeconfig {
+		t.Errorf("got %q, %v want %q", got, err, kubeconfig)
+	}
+	// An explicitly set path that's missing is an error, not the in-cluster config
+	s.cfg.Server.KubeconfigPath = filepath.Join(t.TempDir(), "missing")
+	if got, err := s.kubeConfigPath(); err == nil {
+		t.Errorf("missing explicit kubeconfig: got %q want an error", got)
+	}
+}
+
+func TestKubeConfigPathDefault(t *testing.T) {
+	// Sets HOME, so it can't run in parallel
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s := newTestServer(t)
+	s.cfg.Server.KubeconfigPath = defaultKubeconfigPath
+	// Falls back to the in-cluster config
+	if got, err := s.kubeConfigPath(); err != nil || got != "" {
+		t.Errorf("missing default kubeconfig: got %q, %v want the in-cluster config", got, err)
+	}
+	kubeconfig := filepath.Join(home, ".kube", "config")
+	if err := os.MkdirAll(filepath.Dir(kubeconfig), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.kubeConfigPath(); err != nil || got != kubeconfig {
+		t.Errorf("got %q, %v want %q", got, err, kubeconfig)
+	}
+}
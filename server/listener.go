@@ -0,0 +1,185 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rakhbari/gomux1/logging"
+)
+
+// Listener address schemes understood by listen, on top of plain "host:port".
+const (
+	unixScheme    = "unix://"
+	systemdScheme = "systemd:"
+)
+
+// sdListenFdsStart is the first fd systemd passes sockets in (SD_LISTEN_FDS_START).
+const sdListenFdsStart = 3
+
+// listen binds the listener described by addr, which can be one of:
+//
+//	host:port                                           a TCP socket
+//	unix:///run/gomux1.sock?mode=0660&owner=user:group  a Unix domain socket
+//	systemd: or systemd:<name>                          a socket passed in by systemd
+//
+// A bare "systemd:" adopts the next systemd socket not already adopted, while
+// "systemd:<name>" adopts the one named <name> in FileDescriptorName=.
+func listen(addr string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, unixScheme):
+		return listenUnix(addr)
+	case strings.HasPrefix(addr, systemdScheme):
+		return adoptSystemdListener(strings.TrimPrefix(addr, systemdScheme))
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+func listenUnix(addr string) (net.Listener, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	path := u.Path
+
+	// Remove a socket file left behind by a previous run that didn't get to clean up
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode := u.Query().Get("mode"); mode != "" {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("invalid socket mode %q: %w", mode, err)
+		}
+		if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	if owner := u.Query().Get("owner"); owner != "" {
+		uid, gid, err := lookupOwner(owner)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		if err := os.Chown(path, uid, gid); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+// lookupOwner resolves "user", "user:group" or ":group" (names or numeric IDs)
+// to a uid and gid, with -1 meaning "leave unchanged".
+func lookupOwner(owner string) (uid int, gid int, err error) {
+	uid, gid = -1, -1
+	userName, groupName, _ := strings.Cut(owner, ":")
+	if userName != "" {
+		if uid, err = strconv.Atoi(userName); err != nil {
+			u, err := user.Lookup(userName)
+			if err != nil {
+				return -1, -1, err
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if groupName != "" {
+		if gid, err = strconv.Atoi(groupName); err != nil {
+			g, err := user.LookupGroup(groupName)
+			if err != nil {
+				return -1, -1, err
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// systemdSockets holds the sockets passed in by systemd that haven't been
+// adopted yet. They're read from the environment once since the fds can only
+// be wrapped once.
+var systemdSockets struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners []namedListener
+	err       error
+}
+
+type namedListener struct {
+	name     string
+	listener net.Listener
+}
+
+func adoptSystemdListener(name string) (net.Listener, error) {
+	systemdSockets.once.Do(func() {
+		systemdSockets.listeners, systemdSockets.err = systemdListeners(
+			os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), sdListenFdsStart)
+		// Don't hand the same fds down to any process we start later on
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	if systemdSockets.err != nil {
+		return nil, systemdSockets.err
+	}
+
+	systemdSockets.mu.Lock()
+	defer systemdSockets.mu.Unlock()
+	for i, nl := range systemdSockets.listeners {
+		if name == "" || nl.name == name {
+			systemdSockets.listeners = append(systemdSockets.listeners[:i], systemdSockets.listeners[i+1:]...)
+			slog.Info("Adopting systemd socket", "socket", nl.name, logging.KeyAddr, nl.listener.Addr().String())
+			return nl.listener, nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no systemd sockets left to adopt")
+	}
+	return nil, fmt.Errorf("no systemd socket named %q", name)
+}
+
+// systemdListeners wraps the sockets described by the LISTEN_PID, LISTEN_FDS and
+// LISTEN_FDNAMES env variables (see sd_listen_fds(3)) in net.Listeners.
+func systemdListeners(listenPid string, listenFds string, listenFdNames string, start int) ([]namedListener, error) {
+	if listenPid == "" || listenFds == "" {
+		return nil, fmt.Errorf("no sockets were passed in by systemd (LISTEN_PID/LISTEN_FDS not set)")
+	}
+	if pid, err := strconv.Atoi(listenPid); err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("systemd sockets were passed to another process (LISTEN_PID=%s)", listenPid)
+	}
+	count, err := strconv.Atoi(listenFds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q: %w", listenFds, err)
+	}
+	names := strings.Split(listenFdNames, ":")
+
+	var listeners []namedListener
+	for i := 0; i < count; i++ {
+		name := strconv.Itoa(start + i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(start+i), name)
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket %q: %w", name, err)
+		}
+		listeners = append(listeners, namedListener{name: name, listener: listener})
+	}
+	return listeners, nil
+}
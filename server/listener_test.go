@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// unixClient returns an HTTP client that dials the Unix domain socket at path.
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "gomux1.sock")
+
+	s := newTestServer(t)
+	s.cfg.Server.HttpListen = "unix://" + path + "?mode=0600"
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("socket has wrong mode: got %v want %v", fi.Mode().Perm(), os.FileMode(0600))
+	}
+
+	resp, err := unixClient(path).Get("http://gomux1/v1/ping")
+	if err != nil {
+		t.Fatalf("GET over the Unix socket failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	cancel()
+	<-ran
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("socket file wasn't removed on shutdown")
+	}
+}
+
+func TestListenUnixSocketInvalidMode(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "gomux1.sock")
+	if _, err := listen("unix://" + path + "?mode=rw"); err == nil {
+		t.Error("listen() accepted an invalid socket mode")
+	}
+}
+
+func TestSystemdListeners(t *testing.T) {
+	t.Parallel()
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpListener.Close()
+	f, err := tcpListener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// systemdListeners takes ownership of the fds, so hand it a dup
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := strconv.Itoa(os.Getpid())
+
+	if _, err := systemdListeners(strconv.Itoa(os.Getpid()+1), "1", "", fd); err == nil {
+		t.Error("adopted sockets passed to another process")
+	}
+
+	listeners, err := systemdListeners(pid, "1", "http", fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 || listeners[0].name != "http" {
+		t.Fatalf("wrong listeners adopted: %+v", listeners)
+	}
+	defer listeners[0].listener.Close()
+	if got, want := listeners[0].listener.Addr().String(), tcpListener.Addr().String(); got != want {
+		t.Errorf("adopted listener has wrong address: got %v want %v", got, want)
+	}
+}
+
+func TestLookupOwner(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		owner   string
+		uid     int
+		gid     int
+		wantErr bool
+	}{
+		{owner: "1000", uid: 1000, gid: -1},
+		{owner: "1000:2000", uid: 1000, gid: 2000},
+		{owner: ":2000", uid: -1, gid: 2000},
+		{owner: "no-such-user-gomux1", wantErr: true},
+	}
+	for _, tt := range tests {
+		uid, gid, err := lookupOwner(tt.owner)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("lookupOwner(%q) error = %v, wantErr %v", tt.owner, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (uid != tt.uid || gid != tt.gid) {
+			t.Errorf("lookupOwner(%q) = %d, %d, want %d, %d", tt.owner, uid, gid, tt.uid, tt.gid)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rakhbari/gomux1/apierror"
+	"github.com/rakhbari/gomux1/loadshed"
+	"github.com/rakhbari/gomux1/metrics"
+	"github.com/rakhbari/gomux1/requestid"
+)
+
+// newLoadShedder returns the concurrency limiter configured by LOAD_SHED_*,
+// or nil if load shedding is disabled. It's shared by all listeners.
+func (s *Server) newLoadShedder() (*loadshed.Limiter, error) {
+	cfg := s.cfg.LoadShed
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	var limit loadshed.Limit
+	switch cfg.Limiter {
+	case "static":
+		limit = loadshed.Static(cfg.Limit)
+	case "aimd":
+		limit = loadshed.NewAIMD(cfg.Limit, cfg.MinLimit, cfg.MaxLimit, time.Duration(cfg.TargetLatencyMs)*time.Millisecond)
+	case "gradient":
+		limit = loadshed.NewGradient(cfg.Limit, cfg.MinLimit, cfg.MaxLimit)
+	default:
+		return nil, fmt.Errorf("unknown limiter %q, want static, aimd or gradient", cfg.Limiter)
+	}
+	if cfg.Limiter != "static" && (cfg.MinLimit <= 0 || cfg.MinLimit > cfg.Limit || cfg.Limit > cfg.MaxLimit) {
+		return nil, fmt.Errorf("want 0 < min limit <= limit <= max limit")
+	}
+	return loadshed.New(limit, cfg.QueueSize, time.Duration(cfg.QueueTimeoutMs)*time.Millisecond), nil
+}
+
+// loadShedPriority returns the priority class of r, from the LOAD_SHED_*_PATHS
+// its path is in.
+func (s *Server) loadShedPriority(r *http.Request) loadshed.Priority {
+	switch {
+	case matchPaths(s.cfg.LoadShed.CriticalPaths, r.URL.Path):
+		return loadshed.PriorityCritical
+	case matchPaths(s.cfg.LoadShed.NormalPaths, r.URL.Path):
+		return loadshed.PriorityNormal
+	default:
+		return loadshed.PriorityLow
+	}
+}
+
+// matchPaths reports whether path is one of paths, or under one of those
+// ending with a /.
+func matchPaths(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// shedLoad limits the requests served at once by a listener to what limiter
+// admits. It runs in front of the router, since the route isn't needed to
+// tell the priority class of a request and shed requests should cost as
+// little as possible. Shed requests get a 503 envelope with an E0009 error
+// and a Retry-After header.
+func (s *Server) shedLoad(limiter *loadshed.Limiter, next http.Handler) http.Handler {
+	shed := requestid.Middleware(s.newID)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		retryAfter := max(1, ceilSeconds(limiter.QueueTimeout()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		apiErr := apierror.Unavailable.New("The server is over capacity. Retry in %d seconds", retryAfter)
+		s.HttpResponseWriter(w, r, apiErr.Definition.Status, &StandardApiResponse{Errors: []Error{newError(apiErr)}})
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		priority := s.loadShedPriority(r)
+		token, err := limiter.Acquire(r.Context(), priority)
+		metrics.LoadShedQueued.With().Set(float64(limiter.Queued()))
+		if err != nil {
+			if errors.Is(err, loadshed.ErrShed) {
+				// Not logged, which would add to the load
+				metrics.HttpRequestsShed.With(priority.String()).Inc()
+				shed.ServeHTTP(w, r)
+			}
+			// Otherwise the client went away while queued
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			status := rec.statusOrOK()
+			token.Release(status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout)
+			metrics.LoadShedLimit.With().Set(float64(limiter.Limit()))
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rakhbari/gomux1/loadshed"
+	"github.com/rakhbari/gomux1/requestid"
+)
+
+func TestShedLoad(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, func(s *Server) {
+		s.cfg.LoadShed.CriticalPaths = []string{"/health", "/version"}
+		s.cfg.LoadShed.NormalPaths = []string{"/v1/"}
+	})
+	limiter := loadshed.New(loadshed.Static(1), 10, 20*time.Millisecond)
+	started, unblock := make(chan struct{}), make(chan struct{})
+	handler := s.shedLoad(limiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/slow" {
+			close(started)
+			<-unblock
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+
+	// Take the only slot
+	slow := make(chan int)
+	go func() {
+		slow <- serve("/v1/slow").Code
+	}()
+	<-started
+
+	// Critical requests still get in
+	for _, path := range []string{"/health", "/version"} {
+		if rr := serve(path); rr.Code != http.StatusOK {
+			t.Errorf("%s was shed: %v", path, rr.Code)
+		}
+	}
+
+	// Normal priority requests are queued, then shed with an envelope
+	start := time.Now()
+	rr := serve("/v1/ping")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("wrong status: got %v want 503", rr.Code)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Errorf("request was shed without being queued")
+	}
+	if rr.Header().Get("Retry-After") != "1" || rr.Header().Get(requestid.Header) == "" {
+		t.Errorf("wrong headers: %v", rr.Header())
+	}
+	resp := ExpectedHttpResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("want an envelope, got %q: %v", rr.Body.String(), err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Code != "E0009" || resp.RequestId != rr.Header().Get(requestid.Header) {
+		t.Errorf("wrong envelope: %+v", resp)
+	}
+
+	// Low priority requests are shed right away
+	start = time.Now()
+	if rr := serve("/app/index.html"); rr.Code != http.StatusServiceUnavailable || time.Since(start) >= 20*time.Millisecond {
+		t.Errorf("low priority request wasn't shed right away: %v", rr.Code)
+	}
+
+	// Queued requests are served once the slot is released
+	queued := make(chan int)
+	go func() {
+		queued <- serve("/v1/ping").Code
+	}()
+	for limiter.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(unblock)
+	if code := <-slow; code != http.StatusOK {
+		t.Errorf("slow request got %v", code)
+	}
+	if code := <-queued; code != http.StatusOK {
+		t.Errorf("queued request got %v", code)
+	}
+}
+
+func TestLoadShedInvalidConfig(t *testing.T) {
+	t.Parallel()
+	for _, limiter := range []string{"unknown", "aimd"} {
+		s := newTestServer(t)
+		s.cfg.Server.Listeners = `[{"name":"invalid","addr":"127.0.0.1:0"}]`
+		s.cfg.LoadShed.Enabled = true
+		s.cfg.LoadShed.Limiter = limiter
+		s.cfg.LoadShed.Limit = 10 // No min and max limits
+		if err := s.Run(context.Background()); ExitCode(err) != ExitConfigError {
+			t.Errorf("%s: want a config error, got %v", limiter, err)
+		}
+	}
+}
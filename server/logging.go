@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rakhbari/gomux1/accesslog"
+	"github.com/rakhbari/gomux1/logging"
+	"github.com/rakhbari/gomux1/requestid"
+	"github.com/rakhbari/gomux1/trace"
+)
+
+// requestLogger stores a logger carrying the request's ID, route template,
+// remote address and trace ID (if it's traced) in its context, for handlers to
+// get with logging.FromContext. It also records the route template for the
+// access log, metrics and spans, since the route is only known once the router
+// has matched it. It must run after requestid.Middleware.
+func (s *Server) requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		accesslog.SetRoute(r.Context(), route)
+		setRoute(r.Context(), route)
+		logger := s.logger.With(
+			logging.KeyRequestID, requestid.FromContext(r.Context()),
+			logging.KeyMethod, r.Method,
+			logging.KeyRoute, route,
+			logging.KeyRemoteAddr, r.RemoteAddr,
+		)
+		if sc := trace.SpanFromContext(r.Context()).SpanContext(); sc.IsValid() {
+			logger = logger.With(logging.KeyTraceID, sc.TraceID.String())
+		}
+		next.ServeHTTP(w, r.WithContext(logging.NewContext(r.Context(), logger)))
+	})
+}
+
+// routeTemplate returns the template of the route r matched, e.g.
+// /v1/things/{name}, or its path if the route has no path template.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
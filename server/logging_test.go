@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rakhbari/gomux1/logging"
+)
+
+func TestRequestLogger(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	s := newTestServer(t, WithLogger(logger), WithIDGenerator(func() string { return "req-1" }))
+	router, err := s.NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.Handle("/v1/things/{name}", Handle(s, func(ctx context.Context, _ struct{}) (struct{}, error) {
+		return struct{}{}, errors.New("boom")
+	}))
+
+	req := httptest.NewRequest("GET", "/v1/things/abc", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("want a single JSON log entry, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":                 "Request failed",
+		logging.KeyRequestID:  "req-1",
+		logging.KeyMethod:     "GET",
+		logging.KeyRoute:      "/v1/things/{name}",
+		logging.KeyRemoteAddr: "10.0.0.1:1234",
+		logging.KeyError:      "boom",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("wrong %s: got %v want %v", key, entry[key], value)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rakhbari/gomux1/logging"
+)
+
+// ServerManager owns all of the app's listeners. It binds and starts them
+// together, reports the first fatal error from any of them and shuts them all
+// down together, so neither a failed bind nor a failing TLS server can leave
+// the process half running.
+type ServerManager struct {
+	servers []*managedServer
+	errs    chan error
+	wg      sync.WaitGroup
+	logger  *slog.Logger
+}
+
+type managedServer struct {
+	name     string
+	srv      *http.Server
+	tls      bool
+	listener net.Listener
+	conns    *trackingListener // listener, counting its open connections
+}
+
+func NewServerManager() *ServerManager {
+	return &ServerManager{logger: slog.Default()}
+}
+
+// Add registers a plain HTTP server under the given name.
+func (m *ServerManager) Add(name string, srv *http.Server) {
+	m.servers = append(m.servers, &managedServer{name: name, srv: srv})
+}
+
+// AddTLS registers a TLS server under the given name, serving the certs of its TLSConfig.
+func (m *ServerManager) AddTLS(name string, srv *http.Server) {
+	m.servers = append(m.servers, &managedServer{name: name, srv: srv, tls: true})
+}
+
+// Start binds the listeners of all registered servers and serves each of them
+// in its own goroutine. Listeners handed down by a parent process during an
+// upgrade are adopted instead of being bound again. If any bind fails, the
+// listeners already bound are closed and the error is returned. Errors
+// returned by a server after it has started are reported on Errors().
+func (m *ServerManager) Start() error {
+	inherited, err := inheritedListeners()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Close any inherited listeners that no server claimed
+		for _, listener := range inherited {
+			listener.Close()
+		}
+	}()
+
+	for _, ms := range m.servers {
+		if listener, ok := inherited[ms.name]; ok {
+			m.logger.Info("Adopting inherited listener", logging.KeyListener, ms.name, logging.KeyAddr, listener.Addr().String())
+			delete(inherited, ms.name)
+			ms.listener = listener
+			continue
+		}
+		listener, err := listen(ms.srv.Addr)
+		if err != nil {
+			m.closeListeners()
+			return fmt.Errorf("%s server: %w", ms.name, err)
+		}
+		ms.listener = listener
+	}
+
+	m.errs = make(chan error, len(m.servers))
+	for _, ms := range m.servers {
+		ms.conns = &trackingListener{Listener: ms.listener}
+		m.wg.Add(1)
+		go func(ms *managedServer) {
+			defer m.wg.Done()
+			m.logger.Info("Starting server", logging.KeyListener, ms.name, logging.KeyAddr, ms.listener.Addr().String())
+			var err error
+			if ms.tls {
+				err = ms.srv.ServeTLS(ms.conns, "", "")
+			} else {
+				err = ms.srv.Serve(ms.conns)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				m.logger.Error("Server failed", logging.KeyListener, ms.name, logging.Err(err))
+				m.errs <- fmt.Errorf("%s server: %w", ms.name, err)
+			}
+		}(ms)
+	}
+	return nil
+}
+
+// Errors returns the channel on which fatal server errors are reported.
+func (m *ServerManager) Errors() <-chan error {
+	return m.errs
+}
+
+// Addr returns the address the named server is actually listening on, which
+// differs from the configured one when it was bound to port 0.
+func (m *ServerManager) Addr(name string) string {
+	for _, ms := range m.servers {
+		if ms.name == name && ms.listener != nil {
+			return ms.listener.Addr().String()
+		}
+	}
+	return ""
+}
+
+// Shutdown calls Shutdown on all servers concurrently, waits for them to stop
+// serving (within ctx) and returns the first error encountered (if any). It
+// also waits for the connections hijacked from the servers to be closed, which
+// http.Server.Shutdown doesn't, so h2c connections can finish their streams.
+func (m *ServerManager) Shutdown(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(m.servers))
+	for _, ms := range m.servers {
+		wg.Add(1)
+		go func(ms *managedServer) {
+			defer wg.Done()
+			// Doesn't block if no connections, but will otherwise wait
+			// until the timeout deadline.
+			m.logger.Info("Shutting down server", logging.KeyListener, ms.name)
+			err := ms.srv.Shutdown(ctx)
+			if err == nil && ms.conns != nil {
+				err = ms.conns.wait(ctx)
+			}
+			if err != nil {
+				m.logger.Error("Shutting down server failed", logging.KeyListener, ms.name, logging.Err(err))
+				errs <- fmt.Errorf("%s server: %w", ms.name, err)
+			}
+		}(ms)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+
+	// Wait for the servers' Serve calls to return, but no longer than ctx allows
+	served := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(served)
+	}()
+	select {
+	case <-served:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// listenerFiles returns dups of the bound listeners' file descriptors along
+// with the names of their servers, so they can be handed down to a new process.
+func (m *ServerManager) listenerFiles() ([]*os.File, []string, error) {
+	var files []*os.File
+	var names []string
+	for _, ms := range m.servers {
+		filer, ok := ms.listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+		f, err := filer.File()
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, nil, fmt.Errorf("%s listener: %w", ms.name, err)
+		}
+		files = append(files, f)
+		names = append(names, ms.name)
+	}
+	return files, names, nil
+}
+
+// keepSocketFiles stops Unix domain socket files from being removed when our
+// listeners are closed, since a new process has taken over serving on them.
+func (m *ServerManager) keepSocketFiles() {
+	for _, ms := range m.servers {
+		if listener, ok := ms.listener.(*net.UnixListener); ok {
+			listener.SetUnlinkOnClose(false)
+		}
+	}
+}
+
+func (m *ServerManager) closeListeners() {
+	for _, ms := range m.servers {
+		if ms.listener != nil {
+			ms.listener.Close()
+			ms.listener = nil
+		}
+	}
+}
+
+// trackingListener counts the open connections it accepted, including those
+// hijacked from the http.Server serving them, which it no longer tracks.
+type trackingListener struct {
+	net.Listener
+	open atomic.Int64
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.open.Add(1)
+	return &trackedConn{Conn: conn, l: l}, nil
+}
+
+// wait waits for all the connections to be closed, or ctx to be done.
+func (l *trackingListener) wait(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for l.open.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+type trackedConn struct {
+	net.Conn
+	l    *trackingListener
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { c.l.open.Add(-1) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServerManagerStartAndShutdown(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	manager := NewServerManager()
+	manager.Add("HTTP", s.configureAppServer("127.0.0.1:0", s.Router()))
+	manager.Add("Admin", s.configureAppServer("127.0.0.1:0", s.Router()))
+	if err := manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"HTTP", "Admin"} {
+		resp, err := http.Get("http://" + manager.Addr(name) + "/v1/ping")
+		if err != nil {
+			t.Fatalf("%s server isn't serving: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s server returned wrong status code: got %v want %v", name, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	if err := manager.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() returned error: %v", err)
+	}
+	select {
+	case err := <-manager.Errors():
+		t.Errorf("unexpected server error after a clean shutdown: %v", err)
+	default:
+	}
+}
+
+func TestServerManagerBindFailure(t *testing.T) {
+	t.Parallel()
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	s := newTestServer(t)
+	manager := NewServerManager()
+	manager.Add("HTTP", s.configureAppServer("127.0.0.1:0", s.Router()))
+	manager.Add("TLS", s.configureAppServer(taken.Addr().String(), s.Router()))
+
+	if err := manager.Start(); err == nil {
+		t.Fatal("Start() didn't return an error for a port that's already bound")
+	}
+	if manager.Addr("HTTP") != "" {
+		t.Error("listener bound before the failure was left open")
+	}
+}
+
+func TestRunReturnsServerFailure(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.PreStopDelay = 60
+	// A TLS server without certs fails as soon as it starts serving
+	s.manager.AddTLS("Broken", s.configureAppServer("127.0.0.1:0", s.Router()))
+
+	ran := make(chan error, 1)
+	go func() {
+		ran <- s.Run(context.Background())
+	}()
+
+	select {
+	case err := <-ran:
+		if err == nil {
+			t.Error("Run() didn't return the failing server's error")
+		}
+		if code := ExitCode(err); code != ExitServerError {
+			t.Errorf("wrong exit code: got %d want %d", code, ExitServerError)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() didn't return after a server failure")
+	}
+	if _, err := http.Get("http://" + s.Addr("HTTP") + "/v1/ping"); err == nil {
+		t.Error("HTTP server is still serving after the other server failed")
+	}
+}
+
+func TestRunTLSCertsMissing(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.TlsCertPath = "missing.crt"
+
+	err := s.Run(context.Background())
+	if !errors.Is(err, ErrTLSCerts) {
+		t.Errorf("Run() returned wrong error: got %v want %v", err, ErrTLSCerts)
+	}
+	if code := ExitCode(err); code != ExitConfigError {
+		t.Errorf("wrong exit code: got %d want %d", code, ExitConfigError)
+	}
+}
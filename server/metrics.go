@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rakhbari/gomux1/logging"
+	"github.com/rakhbari/gomux1/metrics"
+)
+
+// unmatchedRoute is the route label of requests that didn't match any route,
+// so they can't blow up the metrics' cardinality.
+const unmatchedRoute = "unmatched"
+
+// routeInfo carries the route template of a request from the router back out
+// to instrument and traceRequests.
+type routeInfo struct {
+	route string
+}
+
+type routeInfoKey struct{}
+
+// setRoute records the route template the request in ctx was routed to.
+func setRoute(ctx context.Context, route string) {
+	if info, ok := ctx.Value(routeInfoKey{}).(*routeInfo); ok {
+		info.route = route
+	}
+}
+
+// withRouteInfo returns the routeInfo of r, adding one to its context if it
+// doesn't have one yet.
+func withRouteInfo(r *http.Request) (*routeInfo, *http.Request) {
+	if info, ok := r.Context().Value(routeInfoKey{}).(*routeInfo); ok {
+		return info, r
+	}
+	info := &routeInfo{}
+	return info, r.WithContext(context.WithValue(r.Context(), routeInfoKey{}, info))
+}
+
+// routeOrUnmatched returns the route template recorded by setRoute, or unmatchedRoute.
+func (info *routeInfo) routeOrUnmatched() string {
+	if info.route == "" {
+		return unmatchedRoute
+	}
+	return info.route
+}
+
+// instrument records the RED metrics of the requests served by a listener. It
+// must wrap the whole router so unmatched requests are counted too.
+func (s *Server) instrument(listener string, next http.Handler) http.Handler {
+	inFlight := metrics.HttpRequestsInFlight.With(listener)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Inc()
+		defer inFlight.Dec()
+		start := time.Now()
+		info, r := withRouteInfo(r)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		labels := []string{info.routeOrUnmatched(), metricMethod(r.Method), metrics.StatusClass(rec.statusOrOK())}
+		metrics.HttpRequests.With(labels...).Inc()
+		metrics.HttpRequestDuration.With(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// metricMethod returns the method label of a request, mapping non-standard
+// methods to "OTHER" to keep the label bounded.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// statusRecorder records the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// statusOrOK returns the status of the response, which is 200 if the handler
+// didn't write anything.
+func (rec *statusRecorder) statusOrOK() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("ResponseWriter doesn't support hijacking")
+	}
+	rec.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// serverErrorLog returns the ErrorLog of a listener's http.Server, which
+// counts TLS handshake errors and sends all of its lines to our logger.
+func (s *Server) serverErrorLog(listener string) *log.Logger {
+	return log.New(&serverErrorWriter{logger: s.logger, listener: listener}, "", 0)
+}
+
+type serverErrorWriter struct {
+	logger   *slog.Logger
+	listener string
+}
+
+func (w *serverErrorWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	if strings.Contains(msg, "TLS handshake error") {
+		metrics.TlsHandshakeErrors.With(w.listener).Inc()
+	}
+	w.logger.Warn(msg, logging.KeyListener, w.listener)
+	return len(p), nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rakhbari/gomux1/metrics"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.Listeners = `[{"name":"metrics-test","addr":"127.0.0.1:0"}]`
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	defer func() {
+		cancel()
+		<-ran
+	}()
+
+	base := "http://" + s.Addr("metrics-test")
+	for _, path := range []string{"/v1/ping", "/no/such/path/123"} {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(base + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != metrics.ContentType {
+		t.Errorf("wrong Content-Type: %v", got)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	for _, series := range []string{
+		`http_requests_total{route="/v1/ping",method="GET",status_class="2xx"} `,
+		`http_request_duration_seconds_bucket{route="/v1/ping",method="GET",status_class="2xx",le="+Inf"} `,
+		`http_requests_total{route="unmatched",method="GET",status_class="4xx"} `,
+		`http_requests_in_flight{listener="metrics-test"} 1`, // The /metrics request itself
+		`build_info{git_sha="",git_branch="",build_timestamp="",go_version="go`,
+		"go_goroutines ",
+	} {
+		if !strings.Contains(string(body), series) {
+			t.Errorf("%q is missing", series)
+		}
+	}
+	if strings.Contains(string(body), "/no/such/path/123") {
+		t.Errorf("raw path used as a route label")
+	}
+}
+
+func TestTLSHandshakeErrorMetric(t *testing.T) {
+	t.Parallel()
+	certPath, keyPath := writeTestCert(t)
+	s := newTestServer(t)
+	s.cfg.Server.TlsCertPath = certPath
+	s.cfg.Server.TlsKeyPath = keyPath
+	s.cfg.Server.Listeners = `[{"name":"handshake-test","addr":"127.0.0.1:0","tls":true}]`
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	defer func() {
+		cancel()
+		<-ran
+	}()
+
+	conn, err := net.Dial("tcp", s.Addr("handshake-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	io.ReadAll(conn)
+	conn.Close()
+
+	counter := metrics.TlsHandshakeErrors.With("handshake-test")
+	for deadline := time.Now().Add(5 * time.Second); counter.Value() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if counter.Value() != 1 {
+		t.Errorf("wrong TLS handshake error count: got %v want 1", counter.Value())
+	}
+}
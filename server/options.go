@@ -0,0 +1,100 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/rakhbari/gomux1/accesslog"
+	"github.com/rakhbari/gomux1/codec"
+	"github.com/rakhbari/gomux1/health"
+	"github.com/rakhbari/gomux1/trace"
+	utils "github.com/rakhbari/gomux1/utils"
+)
+
+// Option customizes a Server created by New.
+type Option func(*Server)
+
+// WithClock sets the clock used to timestamp responses. Defaults to time.Now.
+func WithClock(clock func() time.Time) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithIDGenerator sets the generator of response request IDs. Defaults to random UUIDs.
+func WithIDGenerator(newID func() string) Option {
+	return func(s *Server) {
+		s.newID = newID
+	}
+}
+
+// WithHostName sets the execHost reported in responses. Defaults to $POD_NAME,
+// falling back to the OS host name.
+func WithHostName(hostName string) Option {
+	return func(s *Server) {
+		s.execHost = hostName
+	}
+}
+
+// WithVersionSource sets where the /version payload comes from. Defaults to the
+// build info resolved once at startup by utils.ResolveVersion.
+func WithVersionSource(version func() utils.Version) Option {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// WithEncoder makes responses available in another representation, or replaces
+// the encoder of a built-in format (json, yaml, xml or msgpack).
+func WithEncoder(encoder codec.Encoder) Option {
+	return func(s *Server) {
+		s.encoders.Register(encoder)
+	}
+}
+
+// WithLogger sets the logger of the servers and of request-scoped loggers.
+// Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithAccessLog makes Run write the access log to w instead of where the
+// ACCESS_LOG_* config says.
+func WithAccessLog(w io.Writer) Option {
+	return func(s *Server) {
+		s.accessLog = accesslog.New(w)
+	}
+}
+
+// WithTracer makes Run record spans with tracer instead of one exporting them
+// where the TRACING_* config says. The caller is responsible for shutting it down.
+func WithTracer(tracer *trace.Tracer) Option {
+	return func(s *Server) {
+		s.tracer = tracer
+	}
+}
+
+// WithHealthCheck registers a health check reported by the probe endpoints.
+func WithHealthCheck(check health.Check) Option {
+	return func(s *Server) {
+		s.health.Register(check)
+	}
+}
+
+// WithGracefulTimeout sets the budget for draining the servers on shutdown. Defaults to 15s.
+func WithGracefulTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.gracefulTimeout = timeout
+	}
+}
+
+// WithSignalHandling makes Run drain the servers on SIGINT, SIGTERM and SIGQUIT,
+// and force an exit on a second signal. Only the process owner should enable it.
+func WithSignalHandling() Option {
+	return func(s *Server) {
+		s.handleSignals = true
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rakhbari/gomux1/codec"
+	"github.com/rakhbari/gomux1/logging"
+)
+
+// ProblemContentType is the media type of RFC 7807 Problem Details.
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails is the RFC 7807 rendering of an error response. The standard
+// members are mapped from the response's first Error: type from HelpUrl
+// ("about:blank" if unset), title from Message and detail from Detail. The
+// other envelope fields are carried as extension members.
+type ProblemDetails struct {
+	Type      string  `json:"type"`
+	Title     string  `json:"title"`
+	Status    int     `json:"status"`
+	Detail    string  `json:"detail,omitempty"`
+	Instance  string  `json:"instance"` // The request ID
+	TraceId   string  `json:"traceId,omitempty"`
+	Code      string  `json:"code,omitempty"`
+	Timestamp string  `json:"timestamp"`
+	ExecHost  string  `json:"execHost"`
+	Errors    []Error `json:"errors,omitempty"` // All of the errors, if there's more than one
+}
+
+// wantsProblem reports whether an error response to r should be rendered as
+// Problem Details: when the client asks for application/problem+json by name,
+// or when ERRORS_PROBLEM_DETAILS is set and the response would be JSON anyway.
+func (s *Server) wantsProblem(r *http.Request, encoder codec.Encoder) bool {
+	if codec.Lists(r.Header.Get("Accept"), ProblemContentType) {
+		return true
+	}
+	return s.cfg.Errors.ProblemDetails && encoder.Format == codec.JSON.Format
+}
+
+// newProblemDetails maps an error envelope (with its fields already filled in) to Problem Details.
+func newProblemDetails(status int, apiResp *StandardApiResponse) *ProblemDetails {
+	first := apiResp.Errors[0]
+	problem := &ProblemDetails{
+		Type:      first.HelpUrl,
+		Title:     first.Message,
+		Status:    status,
+		Detail:    first.Detail,
+		Instance:  apiResp.RequestId,
+		TraceId:   apiResp.TraceId,
+		Code:      first.Code,
+		Timestamp: apiResp.Timestamp,
+		ExecHost:  apiResp.ExecHost,
+	}
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(status)
+	}
+	if len(apiResp.Errors) > 1 {
+		problem.Errors = apiResp.Errors
+	}
+	return problem
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, apiResp *StandardApiResponse) {
+	resp, err := json.Marshal(newProblemDetails(status, apiResp))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Encoding problem details failed", logging.Err(err))
+		return
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rakhbari/gomux1/apierror"
+)
+
+func TestProblemDetailsMode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		enabled     bool
+		url         string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{name: "disabled", url: "/v1/ping?format=csv", status: http.StatusNotAcceptable, contentType: "application/json"},
+		{name: "enabled by config", enabled: true, url: "/v1/ping?format=csv", status: http.StatusNotAcceptable, contentType: ProblemContentType},
+		{name: "requested by accept", url: "/v1/ping?format=csv", accept: "application/json, application/problem+json", status: http.StatusNotAcceptable, contentType: ProblemContentType},
+		{name: "wildcard isn't a request", url: "/v1/ping?format=csv", accept: "*/*", status: http.StatusNotAcceptable, contentType: "application/json"},
+		{name: "refused by accept", url: "/v1/ping?format=csv", accept: "application/json, application/problem+json;q=0", status: http.StatusNotAcceptable, contentType: "application/json"},
+		{name: "success keeps the envelope", enabled: true, url: "/v1/ping", accept: "application/problem+json", status: http.StatusOK, contentType: "application/json"},
+	}
+	for _, tt := range tests {
+		s := newTestServer(t)
+		s.cfg.Errors.ProblemDetails = tt.enabled
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rr := httptest.NewRecorder()
+		s.Router().ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: wrong status: got %v want %v", tt.name, rr.Code, tt.status)
+		}
+		if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: wrong Content-Type: got %v want %v", tt.name, got, tt.contentType)
+		}
+	}
+}
+
+func TestProblemDetailsMembers(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, WithIDGenerator(func() string { return "req-1" }), WithHostName("test-host"))
+	req := httptest.NewRequest("GET", "/v1/ping?format=csv", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	problem := map[string]any{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type":     apierror.NotAcceptable.HelpUrl,
+		"status":   float64(http.StatusNotAcceptable),
+		"instance": "req-1",
+		"code":     "E0002",
+		"execHost": "test-host",
+	}
+	for member, value := range want {
+		if problem[member] != value {
+			t.Errorf("wrong %s: got %v want %v", member, problem[member], value)
+		}
+	}
+	for _, member := range []string{"title", "detail", "timestamp"} {
+		if problem[member] == "" || problem[member] == nil {
+			t.Errorf("%s is missing", member)
+		}
+	}
+	if _, ok := problem["errors"]; ok {
+		t.Errorf("errors is set for a single error")
+	}
+}
+
+func TestNewProblemDetails(t *testing.T) {
+	t.Parallel()
+	apiResp := &StandardApiResponse{
+		RequestId: "req-1",
+		Errors: []Error{
+			{Code: "E1", Message: "First", Detail: "first detail", HelpUrl: "https://example.com/errors/E1"},
+			{Code: "E2"},
+		},
+	}
+	problem := newProblemDetails(http.StatusBadRequest, apiResp)
+	if problem.Type != "https://example.com/errors/E1" || problem.Title != "First" || problem.Detail != "first detail" || problem.Code != "E1" {
+		t.Errorf("first error wasn't mapped: %+v", problem)
+	}
+	if problem.Status != http.StatusBadRequest || problem.Instance != "req-1" {
+		t.Errorf("wrong status or instance: %+v", problem)
+	}
+	if len(problem.Errors) != 2 {
+		t.Errorf("all errors should be listed: %+v", problem.Errors)
+	}
+
+	problem = newProblemDetails(http.StatusBadRequest, &StandardApiResponse{Errors: []Error{{Code: "E3"}}})
+	if problem.Title != "Bad Request" {
+		t.Errorf("title should default to the status text: got %v", problem.Title)
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/rakhbari/gomux1/config"
+)
+
+// http2Server returns the HTTP/2 settings shared by all listeners speaking h2 or h2c.
+func (s *Server) http2Server() *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams: s.cfg.Http2.MaxConcurrentStreams,
+		MaxReadFrameSize:     s.cfg.Http2.MaxReadFrameSize,
+		IdleTimeout:          time.Duration(s.cfg.Server.IdleTimeout) * time.Second,
+	}
+}
+
+// configureProtocol sets srv up to speak the protocol configured for its listener.
+func (s *Server) configureProtocol(srv *http.Server, lc config.ListenerConfig) error {
+	switch lc.Protocol {
+	case config.ProtocolHttp1:
+		if lc.Tls {
+			// A non-nil, empty TLSNextProto disables HTTP/2
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+	case config.ProtocolH2:
+		return http2.ConfigureServer(srv, s.http2Server())
+	case config.ProtocolH2c:
+		h2s := s.http2Server()
+		// Only so srv.Shutdown sends the h2c connections, which are hijacked from
+		// srv, a GOAWAY. ServerManager.Shutdown then waits for them to close
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			return err
+		}
+		// Handles both prior knowledge connections and HTTP/1.1 Upgrade requests
+		srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/rakhbari/gomux1/config"
+)
+
+// writeTestCert writes a self-signed cert and key for 127.0.0.1 to a temp dir
+// and returns their paths.
+func writeTestCert(t *testing.T) (certPath string, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gomux1-test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "cert.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func getProtocol(t *testing.T, client *http.Client, url string) (proto string, envelopeProto string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	apiResp := struct {
+		Protocol string `json:"protocol"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Proto, apiResp.Protocol
+}
+
+func TestH2cPriorKnowledge(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Http2.H2c = true
+	s.cfg.Http2.MaxConcurrentStreams = 100
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	defer func() {
+		cancel()
+		<-ran
+	}()
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	proto, envelopeProto := getProtocol(t, h2cClient, "http://"+s.Addr("HTTP")+"/v1/ping")
+	if proto != "HTTP/2.0" || envelopeProto != "HTTP/2.0" {
+		t.Errorf("h2c request wasn't served over HTTP/2: response %s, envelope %s", proto, envelopeProto)
+	}
+
+	// Plain HTTP/1.1 clients must still be served
+	proto, envelopeProto = getProtocol(t, http.DefaultClient, "http://"+s.Addr("HTTP")+"/v1/ping")
+	if proto != "HTTP/1.1" || envelopeProto != "HTTP/1.1" {
+		t.Errorf("HTTP/1.1 request served as: response %s, envelope %s", proto, envelopeProto)
+	}
+}
+
+func TestTLSProtocols(t *testing.T) {
+	t.Parallel()
+	certPath, keyPath := writeTestCert(t)
+	s := newTestServer(t)
+	s.cfg.Server.TlsCertPath = certPath
+	s.cfg.Server.TlsKeyPath = keyPath
+	s.cfg.Server.Listeners = `[{"name":"h2","addr":"127.0.0.1:0","tls":true},{"name":"http1","addr":"127.0.0.1:0","tls":true,"protocol":"http/1.1"}]`
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	defer func() {
+		cancel()
+		<-ran
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	tests := []struct {
+		listener string
+		want     string
+	}{
+		{listener: "h2", want: "HTTP/2.0"},
+		{listener: "http1", want: "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		proto, envelopeProto := getProtocol(t, client, "https://"+s.Addr(tt.listener)+"/v1/ping")
+		if proto != tt.want || envelopeProto != tt.want {
+			t.Errorf("%s listener served: response %s, envelope %s, want %s", tt.listener, proto, envelopeProto, tt.want)
+		}
+	}
+}
+
+func TestTLSChainServedFromMemory(t *testing.T) {
+	t.Parallel()
+	certPath, keyPath := writeTestCert(t)
+	caPath, _ := writeTestCert(t) // Stands in for a CA cert, which is served as is
+	s := newTestServer(t)
+	s.cfg.Server.TlsCertPath = certPath
+	s.cfg.Server.TlsKeyPath = keyPath
+	s.cfg.Server.TlsCaPaths = []string{caPath}
+	s.cfg.Server.Listeners = `[{"name":"a","addr":"127.0.0.1:0","tls":true},{"name":"b","addr":"127.0.0.1:0","tls":true,"caPaths":[]}]`
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	defer func() {
+		cancel()
+		<-ran
+	}()
+
+	for listener, want := range map[string]int{"a": 2, "b": 1} {
+		conn, err := tls.Dial("tcp", s.Addr(listener), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("%s: %v", listener, err)
+		}
+		if got := len(conn.ConnectionState().PeerCertificates); got != want {
+			t.Errorf("%s: served a chain of %d certs, want %d", listener, got, want)
+		}
+		conn.Close()
+	}
+	for _, path := range []string{"tlsCertBundle", "a-tlsCertBundle", "b-tlsCertBundle"} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("a cert bundle was written to %s", path)
+		}
+	}
+}
+
+func TestShutdownWaitsForH2cStreams(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	served := make(chan struct{})
+	var finished atomic.Bool
+	srv := &http.Server{Addr: "127.0.0.1:0", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(served)
+		time.Sleep(300 * time.Millisecond)
+		finished.Store(true)
+		w.Write([]byte("done"))
+	})}
+	if err := s.configureProtocol(srv, config.ListenerConfig{Protocol: config.ProtocolH2c}); err != nil {
+		t.Fatal(err)
+	}
+	m := NewServerManager()
+	m.Add("h2c", srv)
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	body := make(chan string, 1)
+	go func() {
+		resp, err := h2cClient.Get("http://" + m.Addr("h2c") + "/")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-served
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if !finished.Load() {
+		t.Error("Shutdown returned while an h2c stream was in flight")
+	}
+	if got := <-body; got != "done" {
+		t.Errorf("the in-flight h2c stream was cut off: %s", got)
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rakhbari/gomux1/apierror"
+	"github.com/rakhbari/gomux1/config"
+	"github.com/rakhbari/gomux1/metrics"
+	"github.com/rakhbari/gomux1/ratelimit"
+)
+
+// routeLimit is the rate limit of a route and the buckets of its clients.
+type routeLimit struct {
+	config.RateLimitConfig
+	limiter *ratelimit.Limiter
+}
+
+// newRateLimits returns the rate limits configured by RATE_LIMIT_*, keyed by route.
+// They're shared by all listeners, so clients can't get around them by
+// switching listener.
+func newRateLimits(cfg *config.Config) (map[string]*routeLimit, error) {
+	configs, err := cfg.RateLimitConfigs()
+	if err != nil {
+		return nil, err
+	}
+	idle := time.Duration(cfg.RateLimit.IdleTimeout) * time.Second
+	limits := make(map[string]*routeLimit, len(configs))
+	for _, c := range configs {
+		limits[c.Route] = &routeLimit{RateLimitConfig: c, limiter: ratelimit.New(c.Rate, c.Burst, idle, cfg.RateLimit.MaxClients)}
+	}
+	return limits, nil
+}
+
+// rateLimit enforces the rate limits of the routes it wraps. Every response to
+// a limited route has RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset
+// and RateLimit-Policy headers (see draft-ietf-httpapi-ratelimit-headers), and
+// a request over the limit gets a 429 envelope with an E0008 error and a
+// Retry-After header. It must run after requestLogger.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		limit, ok := s.rateLimits[route]
+		if !ok && !probeRoutes[route] {
+			limit, ok = s.rateLimits[config.RateLimitAllRoutes]
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var result ratelimit.Result
+		if key, ip := s.rateLimitKey(r, limit.Key); key != ip {
+			result = limit.limiter.AllowVia(key, ip)
+		} else {
+			result = limit.limiter.Allow(key)
+		}
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		h.Set("RateLimit-Policy", strconv.Itoa(limit.Burst)+";w="+strconv.Itoa(ceilSeconds(time.Duration(float64(limit.Burst)/limit.Rate*float64(time.Second)))))
+		if result.Allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		retryAfter := ceilSeconds(result.RetryAfter)
+		metrics.HttpRateLimited.With(route).Inc()
+		h.Set("Retry-After", strconv.Itoa(retryAfter))
+		s.ErrorResponseWriter(w, r, apierror.TooManyRequests.New("Rate limit of %v requests per second exceeded. Retry in %d seconds", limit.Rate, retryAfter))
+	})
+}
+
+// rateLimitKey returns the key telling the client of r apart for a limit
+// keyed by key (see config.RateLimitConfig), and that of its IP address. The
+// credentials and headers clients are told apart by aren't verified, so
+// they're scoped to the IP address, and a client only gets a bucket of its
+// own once its IP's bucket allows it (see ratelimit.Limiter.AllowVia).
+// Credentials are hashed so they aren't kept in memory.
+func (s *Server) rateLimitKey(r *http.Request, key string) (string, string) {
+	ip := "ip:" + s.clientIP(r)
+	switch {
+	case key == config.RateLimitKeyPrincipal:
+		if user, _, ok := r.BasicAuth(); ok {
+			return ip + " user:" + user, ip
+		}
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+			return ip + " token:" + hash(token), ip
+		}
+	case strings.HasPrefix(key, config.RateLimitKeyHeader):
+		if value := r.Header.Get(strings.TrimPrefix(key, config.RateLimitKeyHeader)); value != "" {
+			return ip + " header:" + hash(value), ip
+		}
+	}
+	return ip, ip
+}
+
+// clientIP returns the IP address of the client of r: the first address of
+// its X-Forwarded-For header if RATE_LIMIT_TRUST_FORWARDED is set, otherwise
+// the address of its connection.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.cfg.RateLimit.TrustForwarded {
+		if first, _, _ := strings.Cut(r.Header.Get("X-Forwarded-For"), ","); strings.TrimSpace(first) != "" {
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// e.g. a Unix domain socket
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ceilSeconds rounds d up to whole seconds, as rate limit headers need.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
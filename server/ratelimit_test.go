@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rakhbari/gomux1/metrics"
+)
+
+// withRateLimits is an Option setting RATE_LIMITS.
+func withRateLimits(limits string) Option {
+	return func(s *Server) {
+		s.cfg.RateLimit.Enabled = true
+		s.cfg.RateLimit.Limits = limits
+		s.cfg.RateLimit.IdleTimeout = 600
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, withRateLimits(`[{"route":"/v1/ping","rate":0.001,"burst":2},{"route":"*","rate":0.001,"burst":1}]`))
+	router := s.Router()
+	ping := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/ping", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	limited := metrics.HttpRateLimited.With("/v1/ping")
+	before := limited.Value()
+
+	for _, remaining := range []string{"1", "0"} {
+		rr := ping("10.0.0.1:1234")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request within the burst got %v", rr.Code)
+		}
+		if rr.Header().Get("RateLimit-Limit") != "2" || rr.Header().Get("RateLimit-Remaining") != remaining || rr.Header().Get("RateLimit-Policy") != "2;w=2000" {
+			t.Errorf("wrong rate limit headers: %v", rr.Header())
+		}
+	}
+
+	rr := ping("10.0.0.1:5678")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("wrong status over the limit: got %v want 429", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" || rr.Header().Get("Retry-After") == "0" {
+		t.Errorf("wrong Retry-After: %q", rr.Header().Get("Retry-After"))
+	}
+	resp := ExpectedHttpResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("want an envelope, got %q: %v", rr.Body.String(), err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Code != "E0008" {
+		t.Errorf("wrong errors: %+v", resp.Errors)
+	}
+	if limited.Value() != before+1 {
+		t.Errorf("rate limited request wasn't counted")
+	}
+
+	// Other clients have their own limit
+	if rr := ping("10.0.0.2:1234"); rr.Code != http.StatusOK {
+		t.Errorf("another client was limited: %v", rr.Code)
+	}
+
+	// The * limit applies to other routes, but not to the health probes
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+		if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("health probe was limited: %v %v", rr.Code, rr.Header())
+		}
+	}
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/errors", nil))
+		codes = append(codes, rr.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("the * limit wasn't applied: %v", codes)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, withRateLimits(`[{"route":"/v1/ping","rate":0.001,"burst":1,"key":"principal"},{"route":"/v1/errors","rate":0.001,"burst":1,"key":"header:X-Api-Key"}]`))
+	router := s.Router()
+	status := func(path, remoteAddr, header, value string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(header, value)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	tests := []struct {
+		path, remoteAddr, header, value string
+		want                            int
+	}{
+		{"/v1/ping", "192.0.2.1:1234", "Authorization", "Bearer token-a", http.StatusOK},
+		{"/v1/ping", "192.0.2.1:1234", "Authorization", "Bearer token-a", http.StatusTooManyRequests},
+		{"/v1/ping", "192.0.2.1:1234", "Authorization", "Bearer token-b", http.StatusTooManyRequests}, // A new token takes from the IP's bucket too
+		{"/v1/ping", "192.0.2.2:1234", "Authorization", "Bearer token-b", http.StatusOK},
+		{"/v1/ping", "192.0.2.3:1234", "Authorization", "Basic dXNlcjpwYXNz", http.StatusOK},                  // user:pass
+		{"/v1/ping", "192.0.2.3:1234", "Authorization", "Basic dXNlcjpvdGhlcg==", http.StatusTooManyRequests}, // user:other
+		{"/v1/ping", "192.0.2.4:1234", "Authorization", "Basic dXNlcjpwYXNz", http.StatusOK},                  // Scoped to the IP
+		{"/v1/errors", "192.0.2.1:1234", "X-Api-Key", "key-a", http.StatusOK},
+		{"/v1/errors", "192.0.2.1:1234", "X-Api-Key", "key-a", http.StatusTooManyRequests},
+		{"/v1/errors", "192.0.2.1:1234", "X-Api-Key", "key-b", http.StatusTooManyRequests},
+		{"/v1/errors", "192.0.2.2:1234", "X-Api-Key", "key-b", http.StatusOK},
+	}
+	for i, tt := range tests {
+		if got := status(tt.path, tt.remoteAddr, tt.header, tt.value); got != tt.want {
+			t.Errorf("request #%d (%s %s from %s): got %v want %v", i+1, tt.path, tt.value, tt.remoteAddr, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, withRateLimits(`[{"route":"/v1/ping","rate":0.001,"burst":1}]`), func(s *Server) {
+		s.cfg.RateLimit.TrustForwarded = true
+	})
+	router := s.Router()
+	for _, tt := range []struct {
+		forwarded string
+		want      int
+	}{
+		{"203.0.113.1, 10.0.0.1", http.StatusOK},
+		{"203.0.113.2, 10.0.0.1", http.StatusOK},
+		{"203.0.113.1", http.StatusTooManyRequests},
+	} {
+		req := httptest.NewRequest("GET", "/v1/ping", nil)
+		req.Header.Set("X-Forwarded-For", tt.forwarded)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: got %v want %v", tt.forwarded, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestRateLimitInvalidConfig(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, withRateLimits(`[{"route":"*"}]`))
+	s.cfg.Server.Listeners = `[{"name":"invalid","addr":"127.0.0.1:0"}]`
+	if err := s.Run(context.Background()); ExitCode(err) != ExitConfigError {
+		t.Errorf("want a config error, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/rakhbari/gomux1/apierror"
+	"github.com/rakhbari/gomux1/logging"
+	"github.com/rakhbari/gomux1/metrics"
+)
+
+// recoverPanics recovers from panics in the handlers it wraps: the panic and
+// its stack are logged with the request-scoped logger, counted, and the client
+// gets a 500 envelope with an E0013 error instead of a dropped connection. The
+// panic and stack are only included in the error's detail when ERRORS_DEBUG is
+// set. If the handler had already started writing its response, the response
+// is aborted instead. It must run after requestLogger.
+func (s *Server) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				// Deliberately aborted response, which net/http handles silently
+				panic(v)
+			}
+			stack := debug.Stack()
+			metrics.HttpPanics.With(routeTemplate(r)).Inc()
+			logging.FromContext(r.Context()).Error("Handler panicked", logging.KeyPanic, fmt.Sprint(v), logging.KeyStack, string(stack))
+			if rec.status != 0 {
+				// Too late for an error response
+				panic(http.ErrAbortHandler)
+			}
+
+			apiErr := &apierror.Error{Definition: apierror.Panic}
+			if s.cfg.Errors.Debug {
+				apiErr.Detail = fmt.Sprintf("panic: %v\n\n%s", v, stack)
+			}
+			s.HttpResponseWriter(w, r, apiErr.Definition.Status, &StandardApiResponse{Errors: []Error{newError(apiErr)}})
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakhbari/gomux1/logging"
+	"github.com/rakhbari/gomux1/metrics"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	t.Parallel()
+	for _, debug := range []bool{false, true} {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, nil))
+		s := newTestServer(t, WithLogger(logger), WithIDGenerator(func() string { return "req-1" }))
+		s.cfg.Errors.Debug = debug
+		router, err := s.NewRouter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		route := "/v1/panic/recover"
+		if debug {
+			route += "-debug"
+		}
+		router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+		panics := metrics.HttpPanics.With(route)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", route, nil))
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("debug=%v: wrong status: got %v want 500", debug, rr.Code)
+		}
+		resp := ExpectedHttpResponse{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("debug=%v: want an envelope, got %q: %v", debug, rr.Body.String(), err)
+		}
+		if resp.RequestId != "req-1" || len(resp.Errors) != 1 || resp.Errors[0].Code != "E0013" {
+			t.Errorf("debug=%v: wrong envelope: %+v", debug, resp)
+		}
+		detail := resp.Errors[0].Detail
+		if debug && (!strings.HasPrefix(detail, "panic: boom\n") || !strings.Contains(detail, "goroutine")) {
+			t.Errorf("debug=%v: want the panic and stack in the detail, got %q", debug, detail)
+		}
+		if !debug && detail != "" {
+			t.Errorf("debug=%v: the detail should be empty, got %q", debug, detail)
+		}
+		if panics.Value() != 1 {
+			t.Errorf("debug=%v: wrong panic count: got %v want 1", debug, panics.Value())
+		}
+
+		entry := map[string]any{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("want a single JSON log entry, got %q: %v", buf.String(), err)
+		}
+		if entry[logging.KeyRequestID] != "req-1" || entry[logging.KeyPanic] != "boom" || !strings.Contains(entry[logging.KeyStack].(string), "recovery_test.go") {
+			t.Errorf("debug=%v: wrong log entry: %v", debug, entry)
+		}
+	}
+}
+
+func TestRecoverPanicsAfterWrite(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, WithLogger(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+	router, err := s.NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.HandleFunc("/v1/panic/after-write", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	})
+
+	// The response can't be turned into an error anymore, so it's aborted
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("want the response aborted, got %v", v)
+		}
+	}()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/panic/after-write", nil))
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rakhbari/gomux1/config"
+	"github.com/rakhbari/gomux1/requestid"
+)
+
+// httpsRedirectRouter returns a router for an HTTP listener in redirect mode:
+// it only serves the health probes and 308-redirects everything else to the
+// same host and URI on httpsPort. 308 (unlike 301) makes clients repeat the
+// request with the same method and body.
+func (s *Server) httpsRedirectRouter(httpsPort int) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(requestid.Middleware(s.newID), s.requestLogger, s.recoverPanics)
+	s.mountProbeRoutes(router)
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, httpsURL(r, httpsPort), http.StatusPermanentRedirect)
+	})
+	router.NotFoundHandler = redirect
+	router.MethodNotAllowedHandler = redirect
+	return router
+}
+
+// httpsPort returns the port HTTP listeners in redirect mode redirect to: that
+// of the first TLS listener bound to a host:port, as set by SERVER_HTTPS_LISTEN
+// or SERVER_LISTENERS, otherwise SERVER_HTTPS_PORT.
+func (s *Server) httpsPort(listeners []config.ListenerConfig) int {
+	for _, lc := range listeners {
+		if !lc.Tls {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(lc.Addr); err == nil {
+			if n, err := strconv.Atoi(port); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	return s.cfg.Server.HttpsPort
+}
+
+// httpsURL returns the https:// URL for r on the given port, keeping its host,
+// path and query.
+func httpsURL(r *http.Request, port int) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return "https://" + host + r.URL.RequestURI()
+}
+
+// hstsHeader returns the Strict-Transport-Security header value for the HSTS
+// config, or "" if HSTS is disabled.
+func (s *Server) hstsHeader() string {
+	hsts := s.cfg.Hsts
+	if hsts.MaxAge <= 0 {
+		return ""
+	}
+	value := fmt.Sprintf("max-age=%d", hsts.MaxAge)
+	if hsts.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if hsts.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// hstsMiddleware adds the Strict-Transport-Security header to all responses of
+// a TLS listener.
+func (s *Server) hstsMiddleware(next http.Handler) http.Handler {
+	value := s.hstsHeader()
+	if value == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHttpsRedirectRouter(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.HttpsPort = 8443
+	router := s.httpsRedirectRouter(8443)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		host         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name:       "Health probe is served",
+			method:     "GET",
+			target:     "/health",
+			host:       "gomux1.example.com:8080",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "API is redirected",
+			method:       "GET",
+			target:       "/v1/ping?x=1",
+			host:         "gomux1.example.com:8080",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://gomux1.example.com:8443/v1/ping?x=1",
+		},
+		{
+			name:         "Bearer token form POST is redirected",
+			method:       "POST",
+			target:       "/v1/bearer-token",
+			host:         "gomux1.example.com",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://gomux1.example.com:8443/v1/bearer-token",
+		},
+		{
+			name:         "IPv6 host",
+			method:       "GET",
+			target:       "/app/",
+			host:         "[::1]:8080",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://[::1]:8443/app/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Host = tt.host
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("wrong status code: got %v want %v", rr.Code, tt.wantStatus)
+			}
+			if location := rr.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("wrong Location: got %q want %q", location, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestHttpsRedirectListenAddr(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.HttpPort = 8080
+	s.cfg.Server.HttpsPort = 8443
+	s.cfg.Server.HttpsListen = ":9443"
+	s.cfg.Server.TlsCertPath = "cert.pem"
+	s.cfg.Server.HttpsRedirect = true
+	listeners, err := s.cfg.ListenerConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port := s.httpsPort(listeners); port != 9443 {
+		t.Fatalf("wrong HTTPS port: got %v want 9443", port)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/ping", nil)
+	req.Host = "gomux1.example.com:8080"
+	rr := httptest.NewRecorder()
+	s.httpsRedirectRouter(s.httpsPort(listeners)).ServeHTTP(rr, req)
+	if got := rr.Header().Get("Location"); got != "https://gomux1.example.com:9443/v1/ping" {
+		t.Errorf("wrong Location: got %v", got)
+	}
+
+	// Listeners declared in SERVER_LISTENERS
+	s.cfg.Server.Listeners = `[{"name":"http","addr":":8080","httpsRedirect":true},{"name":"public","addr":"0.0.0.0:10443","tls":true}]`
+	if listeners, err = s.cfg.ListenerConfigs(); err != nil {
+		t.Fatal(err)
+	}
+	if port := s.httpsPort(listeners); port != 10443 {
+		t.Errorf("wrong HTTPS port of declared listeners: got %v want 10443", port)
+	}
+}
+
+func TestHttpsURLDefaultPort(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest("GET", "/v1/ping", nil)
+	req.Host = "gomux1.example.com:80"
+	if got, want := httpsURL(req, 443), "https://gomux1.example.com/v1/ping"; got != want {
+		t.Errorf("httpsURL() = %q, want %q", got, want)
+	}
+}
+
+func TestHstsMiddleware(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		maxAge            int
+		includeSubDomains bool
+		preload           bool
+		want              string
+	}{
+		{name: "Disabled", maxAge: 0, want: ""},
+		{name: "Max age only", maxAge: 31536000, want: "max-age=31536000"},
+		{name: "All directives", maxAge: 63072000, includeSubDomains: true, preload: true, want: "max-age=63072000; includeSubDomains; preload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.cfg.Hsts.MaxAge = tt.maxAge
+			s.cfg.Hsts.IncludeSubDomains = tt.includeSubDomains
+			s.cfg.Hsts.Preload = tt.preload
+
+			rr := httptest.NewRecorder()
+			s.hstsMiddleware(s.Router()).ServeHTTP(rr, httptest.NewRequest("GET", "/v1/ping", nil))
+			if got := rr.Header().Get("Strict-Transport-Security"); got != tt.want {
+				t.Errorf("wrong Strict-Transport-Security header: got %q want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rakhbari/gomux1/apierror"
+	"github.com/rakhbari/gomux1/bind"
+	"github.com/rakhbari/gomux1/codec"
+	"github.com/rakhbari/gomux1/logging"
+	"github.com/rakhbari/gomux1/requestid"
+	"github.com/rakhbari/gomux1/trace"
+)
+
+type StandardApiResponse struct {
+	RequestId string  `json:"requestId"`
+	TraceId   string  `json:"traceId,omitempty"` // The W3C trace ID of the request, if it's traced
+	Timestamp string  `json:"timestamp"`
+	ExecHost  string  `json:"execHost"`
+	Protocol  string  `json:"protocol"`
+	Payload   any     `json:"payload"`
+	Errors    []Error `json:"errors"`
+}
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail"`
+	HelpUrl string `json:"helpUrl"`
+	Field   string `json:"field,omitempty"` // The invalid request field, for InvalidField errors
+}
+
+// HttpResponseWriter fills in the envelope fields of apiResp and writes it with
+// the given status, in the representation negotiated from the request's
+// ?format= query parameter or Accept header (JSON by default). If none of the
+// requested representations is available a 406 envelope is written in JSON instead,
+// and if encoding apiResp fails a 500 one.
+// Error responses may be rendered as RFC 7807 Problem Details instead (see wantsProblem).
+func (s *Server) HttpResponseWriter(w http.ResponseWriter, r *http.Request, status int, apiResp *StandardApiResponse) {
+	format := r.URL.Query().Get("format")
+	encoder, err := s.encoders.Negotiate(r.Header.Get("Accept"), format)
+	if err != nil && format == "" && codec.Lists(r.Header.Get("Accept"), ProblemContentType) {
+		// A client that only accepts Problem Details still gets JSON success responses
+		encoder, err = s.encoders.Default(), nil
+	}
+	if err != nil {
+		encoder = s.encoders.Default()
+		status = http.StatusNotAcceptable
+		apiErr := apierror.NotAcceptable.New("%v. Supported representations: %s", err, strings.Join(s.encoders.ContentTypes(), ", "))
+		apiResp = &StandardApiResponse{Errors: []Error{newError(apiErr)}}
+	}
+
+	apiResp.RequestId = requestid.FromContext(r.Context())
+	if apiResp.RequestId == "" {
+		// Not served through requestid.Middleware
+		apiResp.RequestId = s.newID()
+	}
+	if sc := trace.SpanFromContext(r.Context()).SpanContext(); sc.IsValid() {
+		apiResp.TraceId = sc.TraceID.String()
+	}
+	apiResp.Timestamp = s.clock().String()
+	apiResp.ExecHost = s.execHost
+	apiResp.Protocol = r.Proto // The negotiated protocol, e.g. HTTP/1.1 or HTTP/2.0
+	if status >= 400 && len(apiResp.Errors) > 0 && s.wantsProblem(r, encoder) {
+		writeProblem(w, r, status, apiResp)
+		return
+	}
+	resp, err := encoder.Marshal(apiResp)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Encoding response failed", logging.KeyFormat, encoder.Format, logging.Err(err))
+		// Fall back to a 500 envelope in JSON, which can always be encoded
+		fallback := *apiResp
+		fallback.Payload = nil
+		fallback.Errors = []Error{newError(apierror.Internal.New("Encoding the response as %s failed", encoder.Format))}
+		encoder, status = codec.JSON, http.StatusInternalServerError
+		resp, _ = encoder.Marshal(&fallback)
+	}
+	w.Header().Set("Content-Type", encoder.ContentType())
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+// ErrorResponseWriter writes an error envelope for err, with the status and
+// error entry of the catalog error it maps to (see apierror.From). Request
+// binding errors are 400s, with an InvalidField error per invalid field, or
+// 413s if the body is too large.
+func (s *Server) ErrorResponseWriter(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid bind.ValidationErrors
+	if errors.As(err, &invalid) {
+		errs := make([]Error, 0, len(invalid))
+		for _, fe := range invalid {
+			e := newError(apierror.InvalidField.New("%s", fe.Message))
+			e.Field = fe.Field
+			errs = append(errs, e)
+		}
+		s.HttpResponseWriter(w, r, apierror.InvalidField.Status, &StandardApiResponse{Errors: errs})
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = apierror.PayloadTooLarge.New("The request body is larger than %d bytes", tooLarge.Limit)
+	} else if errors.Is(err, bind.ErrBody) {
+		err = apierror.BadRequest.Wrap(err)
+	}
+
+	apiErr := apierror.From(err)
+	if apiErr.Definition.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("Request failed", logging.Err(err))
+	}
+	s.HttpResponseWriter(w, r, apiErr.Definition.Status, &StandardApiResponse{Errors: []Error{newError(apiErr)}})
+}
+
+// newError returns the envelope entry for a catalog error.
+func newError(apiErr *apierror.Error) Error {
+	return Error{
+		Code:    apiErr.Definition.Code,
+		Message: apiErr.Definition.Message,
+		Detail:  apiErr.Detail,
+		HelpUrl: apiErr.Definition.HelpUrl,
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakhbari/gomux1/codec"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, WithIDGenerator(func() string { return "req-1" }))
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		status      int
+		contentType string
+		bodyPrefix  string
+	}{
+		{name: "default", url: "/v1/ping", status: http.StatusOK, contentType: "application/json", bodyPrefix: `{"requestId":"req-1"`},
+		{name: "yaml", url: "/v1/ping", accept: "application/yaml", status: http.StatusOK, contentType: "application/yaml", bodyPrefix: "errors: null\nexecHost:"},
+		{name: "xml", url: "/v1/ping", accept: "application/json;q=0.1, text/xml", status: http.StatusOK, contentType: "application/xml", bodyPrefix: `<?xml version="1.0" encoding="UTF-8"?>` + "\n<response><requestId>req-1</requestId>"},
+		{name: "msgpack", url: "/v1/ping", accept: "application/msgpack", status: http.StatusOK, contentType: "application/msgpack", bodyPrefix: "\x86\xa9requestId\xa5req-1"},
+		{name: "format", url: "/v1/ping?format=yaml", accept: "application/json", status: http.StatusOK, contentType: "application/yaml", bodyPrefix: "errors: null"},
+		{name: "not acceptable", url: "/v1/ping", accept: "text/html", status: http.StatusNotAcceptable, contentType: "application/json", bodyPrefix: `{"requestId":"req-1"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rr := httptest.NewRecorder()
+		s.Router().ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: wrong status: got %v want %v", tt.name, rr.Code, tt.status)
+		}
+		if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: wrong Content-Type: got %v want %v", tt.name, got, tt.contentType)
+		}
+		if got := rr.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("%s: wrong Vary: got %v want Accept", tt.name, got)
+		}
+		if !strings.HasPrefix(rr.Body.String(), tt.bodyPrefix) {
+			t.Errorf("%s: unexpected body: got %q want prefix %q", tt.name, rr.Body.String(), tt.bodyPrefix)
+		}
+	}
+}
+
+func TestNotAcceptableEnvelope(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	req := httptest.NewRequest("GET", "/v1/ping?format=csv", nil)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	resp := ExpectedHttpResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Payload != nil {
+		t.Errorf("406 response has a payload: %v", resp.Payload)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Code != "E0002" || !strings.Contains(resp.Errors[0].Detail, "application/msgpack") {
+		t.Errorf("wrong errors: %+v", resp.Errors)
+	}
+}
+
+func TestWithEncoder(t *testing.T) {
+	t.Parallel()
+	csv := codec.Encoder{
+		Format:     "csv",
+		MediaTypes: []string{"text/csv"},
+		Marshal:    func(v any) ([]byte, error) { return []byte("requestId\n" + v.(*StandardApiResponse).RequestId), nil },
+	}
+	s := newTestServer(t, WithIDGenerator(func() string { return "req-1" }), WithEncoder(csv))
+	req := httptest.NewRequest("GET", "/v1/ping", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("wrong Content-Type: got %v want text/csv", got)
+	}
+	if got := rr.Body.String(); got != "requestId\nreq-1" {
+		t.Errorf("wrong body: got %q", got)
+	}
+}
+
+func TestEncodingFailure(t *testing.T) {
+	t.Parallel()
+	broken := codec.Encoder{
+		Format:     "csv",
+		MediaTypes: []string{"text/csv"},
+		Marshal:    func(v any) ([]byte, error) { return nil, errors.New("can't encode the payload") },
+	}
+	s := newTestServer(t, WithEncoder(broken))
+	req := httptest.NewRequest("GET", "/v1/ping", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("wrong status: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("wrong Content-Type: got %v want application/json", got)
+	}
+	resp := ExpectedHttpResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Payload != nil || len(resp.Errors) != 1 || resp.Errors[0].Code != "E0000" {
+		t.Errorf("wrong response: %+v", resp)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rakhbari/gomux1/config"
+	"github.com/rakhbari/gomux1/health"
+	"github.com/rakhbari/gomux1/metrics"
+	"github.com/rakhbari/gomux1/requestid"
+)
+
+// RouteGroup mounts a set of related routes on a router.
+type RouteGroup func(router *mux.Router)
+
+// RouteGroups returns the route groups that can be mounted on a listener, keyed by name.
+func (s *Server) RouteGroups() map[string]RouteGroup {
+	return map[string]RouteGroup{
+		config.RoutesApi:    s.mountApiRoutes,
+		config.RoutesOps:    s.mountOpsRoutes,
+		config.RoutesStatic: s.mountStaticRoutes,
+		config.RoutesDebug:  s.mountDebugRoutes,
+	}
+}
+
+// NewRouter returns a router with the named route groups mounted.
+func (s *Server) NewRouter(groups ...string) (*mux.Router, error) {
+	routeGroups := s.RouteGroups()
+	router := mux.NewRouter()
+	// The request ID is also set here for embedding services mounting the
+	// router, though Run sets it before the router for unmatched routes
+	router.Use(requestid.Middleware(s.newID), s.requestLogger, s.recoverPanics, s.rateLimit)
+	for _, name := range groups {
+		group, ok := routeGroups[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown route group %q", name)
+		}
+		group(router)
+	}
+	return router, nil
+}
+
+// ConfigureAppRouter returns a router with all of the default route groups mounted.
+func (s *Server) ConfigureAppRouter() *mux.Router {
+	router, _ := s.NewRouter(config.DefaultRoutes...)
+	return router
+}
+
+func (s *Server) mountApiRoutes(router *mux.Router) {
+	router.HandleFunc("/v1/ping", s.PingHandler).Methods("GET")
+	router.HandleFunc("/v1/bearer-token", s.BearerTokenFormHandler).Methods("POST")
+	router.HandleFunc("/v1/errors", s.ErrorCatalogHandler).Methods("GET")
+}
+
+func (s *Server) mountOpsRoutes(router *mux.Router) {
+	s.mountProbeRoutes(router)
+	router.HandleFunc("/version", s.VersionHandler).Methods("GET")
+	router.Handle("/metrics", metrics.Default.Handler()).Methods("GET")
+}
+
+// probeRoutes are the health probe routes, which the * rate limit doesn't
+// apply to so they can always be reached.
+var probeRoutes = map[string]bool{"/health": true, "/livez": true, "/readyz": true, "/startupz": true}
+
+// mountProbeRoutes mounts the health probes, which are part of the ops group
+// but also served by HTTP listeners in HTTPS redirect mode.
+func (s *Server) mountProbeRoutes(router *mux.Router) {
+	router.HandleFunc("/health", s.HealthCheckHandler).Methods("GET")
+	router.HandleFunc("/livez", s.probeHandler(health.Liveness)).Methods("GET")
+	router.HandleFunc("/readyz", s.probeHandler(health.Readiness)).Methods("GET")
+	router.HandleFunc("/startupz", s.probeHandler(health.Startup)).Methods("GET")
+}
+
+func (s *Server) mountStaticRoutes(router *mux.Router) {
+	s.ServeStatic(router, s.cfg.WebApp.ContentDir)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestPerListenerRouteGroups(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.Listeners = `[{"name":"public","addr":"127.0.0.1:0","routes":["api"]},{"name":"admin","addr":"127.0.0.1:0","routes":["ops"]}]`
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	defer func() {
+		cancel()
+		<-ran
+	}()
+
+	tests := []struct {
+		listener string
+		path     string
+		want     int
+	}{
+		{listener: "public", path: "/v1/ping", want: http.StatusOK},
+		{listener: "public", path: "/health", want: http.StatusNotFound},
+		{listener: "admin", path: "/health", want: http.StatusOK},
+		{listener: "admin", path: "/v1/ping", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get("http://" + s.Addr(tt.listener) + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s on %s listener: got %v want %v", tt.path, tt.listener, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestUnknownRouteGroup(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.Listeners = `[{"name":"public","addr":"127.0.0.1:0","routes":["nope"]}]`
+
+	err := s.Run(context.Background())
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Run() returned wrong error: got %v want %v", err, ErrInvalidConfig)
+	}
+	if code := ExitCode(err); code != ExitConfigError {
+		t.Errorf("wrong exit code: got %d want %d", code, ExitConfigError)
+	}
+}
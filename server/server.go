@@ -0,0 +1,376 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/rakhbari/gomux1/accesslog"
+	"github.com/rakhbari/gomux1/codec"
+	"github.com/rakhbari/gomux1/config"
+	"github.com/rakhbari/gomux1/health"
+	"github.com/rakhbari/gomux1/logging"
+	"github.com/rakhbari/gomux1/metrics"
+	"github.com/rakhbari/gomux1/requestid"
+	"github.com/rakhbari/gomux1/trace"
+	utils "github.com/rakhbari/gomux1/utils"
+)
+
+// Process exit codes
+const (
+	ExitOK          = 0 // Clean shutdown
+	ExitServerError = 1 // A server failed to start or failed while serving
+	ExitConfigError = 2 // Config or TLS cert files couldn't be loaded
+	ExitForced      = 3 // A second shutdown signal cut the drain short
+)
+
+var (
+	// ErrTLSCerts is returned by Run when the TLS cert files can't be loaded.
+	ErrTLSCerts = errors.New("problem encountered while loading TLS cert files")
+	// ErrInvalidConfig is returned by Run when the listener config is invalid.
+	ErrInvalidConfig = errors.New("invalid config")
+)
+
+// Server is the gomux1 app: its router, response envelope and the HTTP/TLS
+// servers serving them. Everything it depends on is either read from the
+// config.Config passed to New or injected via an Option, so several Servers
+// can live side by side in one process.
+type Server struct {
+	cfg             *config.Config
+	clock           func() time.Time
+	newID           func() string
+	execHost        string
+	version         func() utils.Version
+	logger          *slog.Logger
+	accessLog       *accesslog.Logger
+	tracer          *trace.Tracer
+	rateLimits      map[string]*routeLimit
+	health          *health.Registry
+	rateLimitErr    error // Returned by Run
+	pingHandler     http.HandlerFunc
+	encoders        *codec.Registry
+	gracefulTimeout time.Duration
+	handleSignals   bool
+	upgradeBinary   string
+	upgradeArgs     []string
+	upgradeTimeout  time.Duration
+
+	router  *mux.Router
+	manager *ServerManager
+	ready   atomic.Bool
+	started chan struct{}
+}
+
+func New(cfg *config.Config, opts ...Option) *Server {
+	s := &Server{
+		cfg:             cfg,
+		clock:           time.Now,
+		newID:           func() string { return uuid.New().String() },
+		encoders:        codec.DefaultRegistry(),
+		logger:          slog.Default(),
+		gracefulTimeout: time.Second * 15,
+		upgradeBinary:   cfg.Server.UpgradeBinary,
+		upgradeArgs:     os.Args[1:],
+		upgradeTimeout:  time.Duration(cfg.Server.UpgradeTimeout) * time.Second,
+		health:          health.NewRegistry(),
+		manager:         NewServerManager(),
+		started:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.manager.logger = s.logger
+	if s.upgradeBinary == "" {
+		// Resolved now since the binary on disk may be replaced before an upgrade
+		s.upgradeBinary, _ = os.Executable()
+	}
+	if s.execHost == "" {
+		s.execHost = readExecHost()
+	}
+	if s.version == nil {
+		// Resolved once, since the build info doesn't change while running
+		version := utils.ResolveVersion()
+		s.logger.Info("App version", "git_sha", version.GitSha, "git_branch", version.GitBranch, "timestamp", version.Timestamp,
+			"dirty", version.Dirty, "module_version", version.ModuleVersion, "go_version", version.GoVersion)
+		s.version = func() utils.Version { return version }
+	}
+	version := s.version()
+	metrics.BuildInfo.With(version.GitSha, version.GitBranch, version.Timestamp, runtime.Version()).Set(1)
+
+	s.rateLimits, s.rateLimitErr = newRateLimits(cfg)
+	s.health.Register(health.Check{
+		// Take the app out of rotation as soon as it starts shutting down
+		Name:     "server",
+		Critical: true,
+		Instant:  true,
+		Check: func(context.Context) error {
+			if !s.ready.Load() {
+				return errors.New("not serving or shutting down")
+			}
+			return nil
+		},
+	})
+
+	// Typed handlers are built once, so their tags are checked here
+	s.pingHandler = Handle(s, s.ping)
+	s.router = s.ConfigureAppRouter()
+	return s
+}
+
+// Router returns the app's router, with all of the default route groups mounted,
+// so it can be mounted by an embedding service.
+func (s *Server) Router() *mux.Router {
+	return s.router
+}
+
+// Health returns the app's health check registry, for embedding services to
+// register their checks with. Run starts the checks.
+func (s *Server) Health() *health.Registry {
+	return s.health
+}
+
+// Started returns a channel that's closed once Run has bound all listeners.
+func (s *Server) Started() <-chan struct{} {
+	return s.started
+}
+
+// Addr returns the address the named server ("HTTP" or "TLS") is listening on.
+func (s *Server) Addr(name string) string {
+	return s.manager.Addr(name)
+}
+
+// Run starts the app's listeners (see config.Config.ListenerConfigs) and
+// blocks until ctx is cancelled, a shutdown signal is received (when signal
+// handling is enabled) or one of the servers fails. The servers are drained
+// before Run returns.
+func (s *Server) Run(ctx context.Context) error {
+	listeners, err := s.cfg.ListenerConfigs()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if s.rateLimitErr != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, s.rateLimitErr)
+	}
+
+	accessLog := s.accessLog
+	if accessLog == nil && s.cfg.AccessLog.Enabled {
+		w, err := s.openAccessLog()
+		if err != nil {
+			return fmt.Errorf("%w: access log: %v", ErrInvalidConfig, err)
+		}
+		defer w.Close()
+		accessLog = accesslog.New(w)
+	}
+
+	tracer := s.tracer
+	if tracer == nil {
+		tracer, err = s.newTracer()
+		if err != nil {
+			return fmt.Errorf("%w: tracing: %v", ErrInvalidConfig, err)
+		}
+		defer func() {
+			// Export the spans of the drained requests
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				s.logger.Error("Shutting down tracer failed", logging.Err(err))
+			}
+		}()
+	}
+
+	loadShedder, err := s.newLoadShedder()
+	if err != nil {
+		return fmt.Errorf("%w: load shedding: %v", ErrInvalidConfig, err)
+	}
+
+	db, err := s.registerHealthChecks(listeners)
+	if err != nil {
+		return fmt.Errorf("%w: health checks: %v", ErrInvalidConfig, err)
+	}
+	if db != nil {
+		defer db.Close()
+	}
+
+	var defaultCert *tls.Certificate
+	for _, lc := range listeners {
+		router, err := s.NewRouter(lc.Routes...)
+		if err != nil {
+			return fmt.Errorf("%w: listener %s: %v", ErrInvalidConfig, lc.Name, err)
+		}
+		if lc.HttpsRedirect {
+			router = s.httpsRedirectRouter(s.httpsPort(listeners))
+		}
+		var handler http.Handler = router
+		if lc.Tls {
+			handler = s.hstsMiddleware(handler)
+		}
+		if loadShedder != nil {
+			handler = s.shedLoad(loadShedder, handler)
+		}
+		handler = s.instrument(lc.Name, handler)
+		handler = s.traceRequests(tracer, lc.Name, handler)
+		if accessLog != nil {
+			handler = accessLog.Handler(handler)
+		}
+		// Outside the router too, whose middleware only runs on matched
+		// routes, so 404s and 405s get a request ID as well
+		handler = requestid.Middleware(s.newID)(handler)
+		srv := s.configureAppServer(lc.Addr, handler)
+		srv.ErrorLog = s.serverErrorLog(lc.Name)
+		if err := s.configureProtocol(srv, lc); err != nil {
+			return fmt.Errorf("%w: listener %s: %v", ErrInvalidConfig, lc.Name, err)
+		}
+		if !lc.Tls {
+			s.manager.Add(lc.Name, srv)
+			continue
+		}
+
+		var cert tls.Certificate
+		usesDefault := lc.TlsCertPath == s.cfg.Server.TlsCertPath && lc.TlsKeyPath == s.cfg.Server.TlsKeyPath &&
+			strings.Join(lc.TlsCaPaths, ",") == strings.Join(s.cfg.Server.TlsCaPaths, ",")
+		if usesDefault && defaultCert != nil {
+			// Listeners using the SERVER_TLS_* cert share it
+			cert = *defaultCert
+		} else {
+			cert, err = utils.LoadTlsCertificate(lc.TlsCertPath, lc.TlsKeyPath, lc.TlsCaPaths)
+			if err != nil {
+				s.logger.Error("Loading TLS cert failed", logging.KeyListener, lc.Name, logging.KeyPath, lc.TlsCertPath, logging.Err(err))
+				return fmt.Errorf("listener %s: %w: %v", lc.Name, ErrTLSCerts, err)
+			}
+			if usesDefault {
+				defaultCert = &cert
+			}
+		}
+		s.logger.Info("Loaded TLS cert chain", logging.KeyListener, lc.Name, logging.KeyPath, lc.TlsCertPath, "chain_length", len(cert.Certificate))
+		if srv.TLSConfig == nil {
+			srv.TLSConfig = &tls.Config{}
+		}
+		srv.TLSConfig.Certificates = []tls.Certificate{cert}
+		s.manager.AddTLS(lc.Name, srv)
+	}
+
+	if err := s.manager.Start(); err != nil {
+		return err
+	}
+	healthCtx, stopHealth := context.WithCancel(ctx)
+	defer stopHealth()
+	s.health.Start(healthCtx)
+	s.ready.Store(true)
+	close(s.started)
+	notifyParentReady()
+
+	coordinator := newCoordinator(s, time.Duration(s.cfg.Server.PreStopDelay)*time.Second, s.gracefulTimeout)
+	if s.handleSignals {
+		coordinator.Notify()
+		defer coordinator.Stop()
+	}
+
+	// Block until we're told to stop (or a server fails) and the servers have drained.
+	return coordinator.Wait(ctx)
+}
+
+// openAccessLog opens the access log file configured by ACCESS_LOG_*, or
+// returns stdout if no path is set.
+func (s *Server) openAccessLog() (io.WriteCloser, error) {
+	cfg := s.cfg.AccessLog
+	if cfg.Path == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	f := &accesslog.RotatingFile{
+		Path:       cfg.Path,
+		MaxSize:    int64(cfg.MaxSizeMb) << 20,
+		MaxAge:     time.Duration(cfg.MaxAgeHours) * time.Hour,
+		MaxBackups: cfg.MaxBackups,
+		Compress:   cfg.Compress,
+	}
+	// Fail at startup rather than on the first request if the file can't be written
+	if _, err := f.Write(nil); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// newTracer returns a Tracer exporting spans where TRACING_* says.
+func (s *Server) newTracer() (*trace.Tracer, error) {
+	cfg := s.cfg.Tracing
+	switch cfg.Exporter {
+	case "":
+		return trace.New(nil), nil
+	case "jsonl":
+		if cfg.Path == "" {
+			return trace.New(trace.NewJSONLinesExporter(nopCloser{os.Stdout})), nil
+		}
+		f, err := os.OpenFile(cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return trace.New(trace.NewJSONLinesExporter(f)), nil
+	case "otlp":
+		headers := http.Header{}
+		for _, header := range cfg.OtlpHeaders {
+			key, value, ok := strings.Cut(header, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid OTLP header %q, want key=value", header)
+			}
+			headers.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+		return trace.New(trace.NewOTLPExporter(cfg.OtlpEndpoint, headers, cfg.ServiceName)), nil
+	default:
+		return nil, fmt.Errorf("unknown exporter %q, want jsonl or otlp", cfg.Exporter)
+	}
+}
+
+// nopCloser keeps stdout open when the access log or span exporter is closed.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// ExitCode maps the error returned by Run to a process exit code.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrTLSCerts), errors.Is(err, ErrInvalidConfig):
+		return ExitConfigError
+	default:
+		return ExitServerError
+	}
+}
+
+func (s *Server) configureAppServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr: addr,
+		// Good practice to set timeouts to avoid Slowloris attacks.
+		WriteTimeout: time.Duration(s.cfg.Server.WriteTimeout) * time.Second,
+		ReadTimeout:  time.Duration(s.cfg.Server.ReadTimeout) * time.Second,
+		IdleTimeout:  time.Duration(s.cfg.Server.IdleTimeout) * time.Second,
+		Handler:      handler, // Pass in our instance of gorilla/mux.Router (possibly wrapped in middleware)
+	}
+}
+
+func readExecHost() string {
+	execHost := os.Getenv("POD_NAME")
+	if execHost == "" {
+		hostname, err := os.Hostname()
+		if err == nil {
+			execHost = hostname
+		} else {
+			execHost = "N/A"
+		}
+	}
+	return execHost
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rakhbari/gomux1/logging"
+)
+
+// ShutdownSignals are the signals that kick off the graceful drain sequence.
+var ShutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT}
+
+// coordinator drives a Server's shutdown. It marks the Server not ready so
+// /health and /readyz start failing and the pod's endpoints get deprogrammed before the
+// servers are drained.
+type coordinator struct {
+	server       *Server
+	preStopDelay time.Duration // How long to keep serving (while unhealthy) before draining
+	timeout      time.Duration // Budget for draining all servers
+	exit         func(code int)
+
+	signals chan os.Signal
+}
+
+func newCoordinator(s *Server, preStopDelay time.Duration, timeout time.Duration) *coordinator {
+	return &coordinator{
+		server:       s,
+		preStopDelay: preStopDelay,
+		timeout:      timeout,
+		exit:         os.Exit,
+		signals:      make(chan os.Signal, 2),
+	}
+}
+
+// Notify registers the coordinator for the shutdown, upgrade and dump signals.
+func (c *coordinator) Notify() {
+	signal.Notify(c.signals, ShutdownSignals...)
+	signal.Notify(c.signals, UpgradeSignals...)
+	signal.Notify(c.signals, DumpSignals...)
+}
+
+// Stop undoes Notify.
+func (c *coordinator) Stop() {
+	signal.Stop(c.signals)
+}
+
+// Wait blocks until ctx is cancelled, a shutdown signal is received or one of
+// the managed servers fails.
+//
+// On cancellation or a signal it runs the drain sequence: mark the app not
+// ready, wait out the pre-stop delay, and shut all servers down concurrently
+// within the timeout. A signal received at any point during the drain forces
+// an immediate exit.
+//
+// On a dump signal it logs a goroutine dump and keeps serving.
+//
+// On an upgrade signal it hands our listeners to a new process and, once that
+// process is serving, drains without the pre-stop delay since the listeners
+// keep accepting connections throughout. A failed upgrade is logged and we
+// keep serving.
+//
+// On a server failure the remaining servers are shut down right away and the
+// server's error is returned.
+func (c *coordinator) Wait(ctx context.Context) error {
+	for {
+		select {
+		case err := <-c.server.manager.Errors():
+			c.server.logger.Error("Server failed, shutting down remaining servers", logging.Err(err))
+			c.server.ready.Store(false)
+			c.shutdown()
+			return err
+		case sig := <-c.signals:
+			if isDumpSignal(sig) {
+				c.server.logGoroutines()
+				continue
+			}
+			if !isUpgradeSignal(sig) {
+				c.server.logger.Info("Received signal, starting graceful shutdown", logging.KeySignal, sig.String())
+				return c.drain(c.preStopDelay)
+			}
+			c.server.logger.Info("Received signal, starting binary upgrade", logging.KeySignal, sig.String())
+			child, err := c.server.upgrade()
+			if err != nil {
+				c.server.logger.Error("Upgrade failed, continuing to serve", logging.Err(err))
+				continue
+			}
+			c.server.logger.Info("New process is serving, draining", logging.KeyPid, child.Pid)
+			return c.drain(0)
+		case <-ctx.Done():
+			c.server.logger.Info("Context cancelled, starting graceful shutdown")
+			return c.drain(c.preStopDelay)
+		}
+	}
+}
+
+// drain marks the app not ready, waits out preStopDelay and shuts all servers
+// down. A signal received at any point during the drain forces an immediate
+// exit, except for a dump signal, since a stuck drain is when a dump is most useful.
+func (c *coordinator) drain(preStopDelay time.Duration) error {
+	c.server.ready.Store(false)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-c.signals:
+				if isDumpSignal(sig) {
+					c.server.logGoroutines()
+					continue
+				}
+				c.server.logger.Warn("Received second signal, forcing exit", logging.KeySignal, sig.String())
+				c.exit(ExitForced)
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if preStopDelay > 0 {
+		c.server.logger.Info("Waiting for endpoints to be deprogrammed", logging.KeyDuration, preStopDelay.String())
+		time.Sleep(preStopDelay)
+	}
+
+	return c.shutdown()
+}
+
+func (c *coordinator) shutdown() error {
+	// Create a deadline to wait for.
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	return c.server.manager.Shutdown(ctx)
+}
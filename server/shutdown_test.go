@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startTestServer runs s in the background and waits for its listeners to be bound.
+func startTestServer(t *testing.T, s *Server, ctx context.Context) chan error {
+	ran := make(chan error, 1)
+	go func() {
+		ran <- s.Run(ctx)
+	}()
+	select {
+	case <-s.Started():
+	case err := <-ran:
+		t.Fatalf("Run() returned early: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("servers didn't start")
+	}
+	return ran
+}
+
+func TestRunDrainsOnCancel(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.PreStopDelay = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+
+	cancel()
+
+	// During the pre-stop delay /health must report unhealthy while the server keeps serving
+	time.Sleep(50 * time.Millisecond)
+	resp, err := http.Get("http://" + s.Addr("HTTP") + "/health")
+	if err != nil {
+		t.Fatalf("server stopped serving during the pre-stop delay: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("/health returned wrong status code during drain: got %v want %v",
+			resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	select {
+	case err := <-ran:
+		if err != nil {
+			t.Errorf("Run() returned error: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run() didn't return within the graceful timeout")
+	}
+	if _, err := http.Get("http://" + s.Addr("HTTP") + "/health"); err == nil {
+		t.Error("HTTP server is still serving after shutdown")
+	}
+}
+
+func TestCoordinatorDrainOnSignal(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.manager.Add("HTTP", s.configureAppServer("127.0.0.1:0", s.Router()))
+	s.manager.Add("TLS", s.configureAppServer("127.0.0.1:0", s.Router()))
+	if err := s.manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newCoordinator(s, 0, time.Second)
+	c.exit = func(code int) { t.Errorf("unexpected forced exit with code %d", code) }
+	waited := make(chan error, 1)
+	go func() {
+		waited <- c.Wait(context.Background())
+	}()
+	c.signals <- syscall.SIGTERM
+
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Wait() returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait() didn't return within the graceful timeout")
+	}
+	if s.ready.Load() {
+		t.Error("server still marked ready after shutdown")
+	}
+	for _, name := range []string{"HTTP", "TLS"} {
+		if _, err := http.Get("http://" + s.Addr(name) + "/health"); err == nil {
+			t.Errorf("%s server is still serving after shutdown", name)
+		}
+	}
+}
+
+func TestCoordinatorForcedExit(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.manager.Add("HTTP", s.configureAppServer("127.0.0.1:0", s.Router()))
+	if err := s.manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.manager.Shutdown(context.Background())
+
+	c := newCoordinator(s, time.Second, time.Second)
+	exitCode := make(chan int, 1)
+	c.exit = func(code int) { exitCode <- code }
+
+	go c.Wait(context.Background())
+	c.signals <- syscall.SIGTERM
+	c.signals <- syscall.SIGINT
+
+	select {
+	case code := <-exitCode:
+		if code != ExitForced {
+			t.Errorf("forced exit with wrong code: got %d want %d", code, ExitForced)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("second signal didn't force an exit")
+	}
+}
+
+func TestHealthCheckHandlerNotReady(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.ready.Store(false)
+
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusServiceUnavailable)
+	}
+}
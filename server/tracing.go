@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rakhbari/gomux1/trace"
+)
+
+// traceRequests records a server span per request served by a listener,
+// continuing the trace passed in the request's traceparent header, if any.
+// Handlers can start child spans with trace.Start(r.Context(), ...). It must
+// wrap the whole router so unmatched requests are traced too.
+func (s *Server) traceRequests(tracer *trace.Tracer, listener string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(trace.Extract(r.Context(), r.Header), metricMethod(r.Method), trace.SpanKindServer)
+		defer span.End()
+		info, r := withRouteInfo(r.WithContext(ctx))
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// Attribute names from the OpenTelemetry HTTP semantic conventions
+		status := rec.statusOrOK()
+		span.SetName(metricMethod(r.Method) + " " + info.routeOrUnmatched())
+		span.SetAttribute("http.request.method", r.Method)
+		if info.route != "" {
+			span.SetAttribute("http.route", info.route)
+		}
+		span.SetAttribute("url.path", r.URL.Path)
+		span.SetAttribute("http.response.status_code", status)
+		span.SetAttribute("network.protocol.version", strings.TrimPrefix(r.Proto, "HTTP/"))
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			span.SetAttribute("client.address", host)
+		}
+		span.SetAttribute("server.listener", listener)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(trace.StatusError, http.StatusText(status))
+		}
+	})
+}
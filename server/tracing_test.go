@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rakhbari/gomux1/trace"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// exportedSpan is the part of a trace.JSONLinesExporter line we check.
+type exportedSpan struct {
+	TraceId      string         `json:"trace_id"`
+	SpanId       string         `json:"span_id"`
+	ParentSpanId string         `json:"parent_span_id"`
+	Name         string         `json:"name"`
+	Kind         string         `json:"kind"`
+	Attributes   map[string]any `json:"attributes"`
+}
+
+func TestTraceRequests(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	tracer := trace.New(trace.NewJSONLinesExporter(&buf))
+	s := newTestServer(t, WithTracer(tracer))
+	s.cfg.Server.Listeners = `[{"name":"trace-test","addr":"127.0.0.1:0"}]`
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	base := "http://" + s.Addr("trace-test")
+
+	req, _ := http.NewRequest("GET", base+"/v1/ping", nil)
+	req.Header.Set(trace.TraceparentHeader, testTraceparent)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apiResp StandardApiResponse
+	json.NewDecoder(resp.Body).Decode(&apiResp)
+	resp.Body.Close()
+	if apiResp.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("wrong traceId in envelope: %q", apiResp.TraceId)
+	}
+
+	// Requests without a traceparent start a new trace
+	resp, err = http.Get(base + "/no/such/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	<-ran
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var spans []exportedSpan
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var span exportedSpan
+		if err := dec.Decode(&span); err != nil {
+			t.Fatal(err)
+		}
+		spans = append(spans, span)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got %+v", spans)
+	}
+	ping, unmatched := spans[0], spans[1]
+	if ping.Name != "GET /v1/ping" || ping.Kind != "server" || ping.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || ping.ParentSpanId != "00f067aa0ba902b7" {
+		t.Errorf("wrong ping span: %+v", ping)
+	}
+	if ping.Attributes["http.route"] != "/v1/ping" || ping.Attributes["http.response.status_code"] != float64(200) || ping.Attributes["server.listener"] != "trace-test" {
+		t.Errorf("wrong ping span attributes: %+v", ping.Attributes)
+	}
+	if unmatched.Name != "GET unmatched" || unmatched.ParentSpanId != "" || unmatched.TraceId == ping.TraceId || unmatched.Attributes["http.response.status_code"] != float64(404) {
+		t.Errorf("wrong unmatched span: %+v", unmatched)
+	}
+}
+
+func TestTracingOTLPConfig(t *testing.T) {
+	t.Parallel()
+	bodies := make(chan []byte, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("missing OTLP header: %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer collector.Close()
+
+	s := newTestServer(t)
+	s.cfg.Server.Listeners = `[{"name":"otlp-test","addr":"127.0.0.1:0"}]`
+	s.cfg.Tracing.Exporter = "otlp"
+	s.cfg.Tracing.OtlpEndpoint = collector.URL + "/v1/traces"
+	s.cfg.Tracing.OtlpHeaders = []string{"X-Api-Key=secret"}
+	s.cfg.Tracing.ServiceName = "gomux1-test"
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	resp, err := http.Get("http://" + s.Addr("otlp-test") + "/v1/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	cancel()
+	<-ran // Run exports the queued spans before returning
+
+	select {
+	case body := <-bodies:
+		for _, want := range []string{`"gomux1-test"`, `"GET /v1/ping"`} {
+			if !bytes.Contains(body, []byte(want)) {
+				t.Errorf("%s missing from OTLP request %s", want, body)
+			}
+		}
+	default:
+		t.Error("no spans were sent to the collector")
+	}
+}
+
+func TestTracingInvalidConfig(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t)
+	s.cfg.Server.Listeners = `[{"name":"invalid","addr":"127.0.0.1:0"}]`
+	s.cfg.Tracing.Exporter = "zipkin"
+	if err := s.Run(context.Background()); ExitCode(err) != ExitConfigError {
+		t.Errorf("want a config error, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Env variables used to hand listeners down from a parent process to its
+// replacement during a binary upgrade.
+const (
+	envInheritedListeners = "GOMUX1_INHERITED_LISTENERS" // Comma-delimited server names, in fd order starting at 3
+	envReadyFd            = "GOMUX1_READY_FD"            // Pipe fd the child writes to once it's serving
+)
+
+// UpgradeSignals trigger a zero-downtime binary upgrade.
+var UpgradeSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
+
+func isUpgradeSignal(sig os.Signal) bool {
+	for _, s := range UpgradeSignals {
+		if sig == s {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritedListeners returns the listeners handed down by a parent process
+// during an upgrade, keyed by server name. It returns nil if we weren't
+// started by an upgrade.
+func inheritedListeners() (map[string]net.Listener, error) {
+	names := os.Getenv(envInheritedListeners)
+	if names == "" {
+		return nil, nil
+	}
+	// Don't hand the same fds down to any process we start later on
+	os.Unsetenv(envInheritedListeners)
+
+	listeners := map[string]net.Listener{}
+	for i, name := range strings.Split(names, ",") {
+		f := os.NewFile(uintptr(3+i), name)
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherited %s listener: %w", name, err)
+		}
+		listeners[name] = listener
+	}
+	return listeners, nil
+}
+
+// notifyParentReady tells the parent process (if any) that we're serving so it
+// can start draining.
+func notifyParentReady() {
+	fdStr := os.Getenv(envReadyFd)
+	if fdStr == "" {
+		return
+	}
+	os.Unsetenv(envReadyFd)
+
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	f.Write([]byte("ready\n"))
+}
+
+// upgrade starts a new copy of the app's binary, hands it all of our bound
+// listeners and waits for it to report that it's serving. The caller is then
+// free to drain and exit; connections keep being accepted by the new process.
+// If the new process fails or doesn't become ready in time it's killed and an
+// error is returned, leaving us serving as before.
+func (s *Server) upgrade() (*os.Process, error) {
+	files, names, err := s.manager.listenerFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(s.upgradeBinary, s.upgradeArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		envInheritedListeners+"="+strings.Join(names, ","),
+		fmt.Sprintf("%s=%d", envReadyFd, 3+len(files)),
+	)
+	err = cmd.Start()
+	readyW.Close()
+	// Handing the fds to the new process put them in blocking mode, and with them
+	// our own listeners since they share file status flags. Undo that so our
+	// listeners can still be closed when we drain.
+	for _, f := range files {
+		syscall.SetNonblock(int(f.Fd()), true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	ready := make(chan error, 1)
+	go func() {
+		_, err := bufio.NewReader(readyR).ReadString('\n')
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err == nil {
+			s.manager.keepSocketFiles()
+			return cmd.Process, nil
+		}
+		cmd.Process.Kill()
+		return nil, fmt.Errorf("new process closed its ready pipe: %w", err)
+	case err := <-exited:
+		return nil, fmt.Errorf("new process exited before becoming ready: %v", err)
+	case <-time.After(s.upgradeTimeout):
+		cmd.Process.Kill()
+		return nil, errors.New("timed out waiting for new process to become ready")
+	}
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const envUpgradeTestChild = "GOMUX1_TEST_UPGRADE_CHILD"
+
+// TestUpgradeChildProcess is the new process started by TestUpgradeHandsOffListeners.
+func TestUpgradeChildProcess(t *testing.T) {
+	if os.Getenv(envUpgradeTestChild) != "1" {
+		t.Skip("only runs as the child of TestUpgradeHandsOffListeners")
+	}
+	s := newTestServer(t, WithHostName("child"), WithSignalHandling())
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func execHostOf(t *testing.T, addr string) string {
+	resp, err := http.Get("http://" + addr + "/v1/ping")
+	if err != nil {
+		t.Fatalf("GET /v1/ping failed: %v", err)
+	}
+	defer resp.Body.Close()
+	apiResp := ExpectedHttpResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		t.Fatal(err)
+	}
+	return apiResp.ExecHost
+}
+
+func TestUpgradeHandsOffListeners(t *testing.T) {
+	t.Setenv(envUpgradeTestChild, "1")
+
+	s := newTestServer(t, WithHostName("parent"))
+	s.upgradeBinary = os.Args[0]
+	s.upgradeArgs = []string{"-test.run=^TestUpgradeChildProcess$"}
+	s.upgradeTimeout = 10 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	addr := s.Addr("HTTP")
+
+	if host := execHostOf(t, addr); host != "parent" {
+		t.Fatalf("request served by %q before the upgrade, want parent", host)
+	}
+
+	child, err := s.upgrade()
+	if err != nil {
+		t.Fatalf("upgrade() failed: %v", err)
+	}
+	defer func() {
+		child.Signal(syscall.SIGTERM)
+		child.Wait()
+	}()
+
+	// Drain the parent
+	cancel()
+	if err := <-ran; err != nil {
+		t.Errorf("draining the parent failed: %v", err)
+	}
+
+	// The same address must now be served by the child
+	if host := execHostOf(t, addr); host != "child" {
+		t.Errorf("request served by %q after the upgrade, want child", host)
+	}
+}
+
+func TestUpgradeFailureKeepsServing(t *testing.T) {
+	s := newTestServer(t)
+	s.upgradeBinary = "/nonexistent/gomux1"
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := startTestServer(t, s, ctx)
+	defer func() {
+		cancel()
+		<-ran
+	}()
+
+	if _, err := s.upgrade(); err == nil {
+		t.Fatal("upgrade() to a missing binary didn't fail")
+	}
+	if host := execHostOf(t, s.Addr("HTTP")); host == "" {
+		t.Error("server stopped serving after a failed upgrade")
+	}
+}
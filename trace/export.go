@@ -0,0 +1,90 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Exporter sends ended spans somewhere, e.g. a file or a collector.
+type Exporter interface {
+	// ExportSpans exports a batch of spans. It's never called concurrently.
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	// Shutdown releases the exporter's resources. ExportSpans isn't called afterwards.
+	Shutdown(ctx context.Context) error
+}
+
+// JSONLinesExporter writes each span as a line of JSON.
+type JSONLinesExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesExporter returns an Exporter writing spans to w, which is closed
+// on Shutdown if it's an io.Closer.
+func NewJSONLinesExporter(w io.Writer) *JSONLinesExporter {
+	return &JSONLinesExporter{w: w}
+}
+
+// jsonSpan is a line written by the JSONLinesExporter.
+type jsonSpan struct {
+	TraceId       string         `json:"trace_id"`
+	SpanId        string         `json:"span_id"`
+	ParentSpanId  string         `json:"parent_span_id,omitempty"`
+	TraceState    string         `json:"trace_state,omitempty"`
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	DurationMs    float64        `json:"duration_ms"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Status        string         `json:"status"`
+	StatusMessage string         `json:"status_message,omitempty"`
+}
+
+func (e *JSONLinesExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	var buf []byte
+	for _, span := range spans {
+		line := jsonSpan{
+			TraceId:       span.SpanContext.TraceID.String(),
+			SpanId:        span.SpanContext.SpanID.String(),
+			TraceState:    span.SpanContext.TraceState,
+			Name:          span.Name,
+			Kind:          span.Kind.String(),
+			Start:         span.Start,
+			End:           span.End,
+			DurationMs:    float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+			Status:        span.Status.String(),
+			StatusMessage: span.StatusMessage,
+		}
+		if span.ParentSpanID.IsValid() {
+			line.ParentSpanId = span.ParentSpanID.String()
+		}
+		if len(span.Attributes) > 0 {
+			line.Attributes = make(map[string]any, len(span.Attributes))
+			for _, attr := range span.Attributes {
+				line.Attributes[attr.Key] = attr.Value
+			}
+		}
+		b, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := e.w.Write(buf)
+	return err
+}
+
+func (e *JSONLinesExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// OTLPExporter sends spans to an OpenTelemetry collector with OTLP/HTTP, using
+// its JSON encoding.
+type OTLPExporter struct {
+	endpoint    string
+	headers     http.Header
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter returns an Exporter posting spans to endpoint, the full URL
+// of a collector's traces endpoint (e.g. http://localhost:4318/v1/traces),
+// with the extra headers given (e.g. for authentication). Spans are reported
+// as coming from the service serviceName.
+func NewOTLPExporter(endpoint string, headers http.Header, serviceName string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// The subset of the OTLP ExportTraceServiceRequest message we send
+// (https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding).
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceId           string         `json:"traceId"`
+		SpanId            string         `json:"spanId"`
+		ParentSpanId      string         `json:"parentSpanId,omitempty"`
+		TraceState        string         `json:"traceState,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"` // A single {"<type>Value": value} pair
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// otlpScopeName is the instrumentation scope our spans are reported under.
+const otlpScopeName = "github.com/rakhbari/gomux1"
+
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: otlpScopeName}, Spans: make([]otlpSpan, 0, len(spans))}
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceId:           span.SpanContext.TraceID.String(),
+			SpanId:            span.SpanContext.SpanID.String(),
+			TraceState:        span.SpanContext.TraceState,
+			Name:              span.Name,
+			Kind:              otlpKind(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: int(span.Status), Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			s.ParentSpanId = span.ParentSpanID.String()
+		}
+		for _, attr := range span.Attributes {
+			s.Attributes = append(s.Attributes, otlpAttribute(attr))
+		}
+		scope.Spans = append(scope.Spans, s)
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{otlpAttribute(Attribute{Key: "service.name", Value: e.serviceName})}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range e.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP collector responded %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// otlpKind maps a SpanKind to the OTLP SpanKind enum.
+func otlpKind(kind SpanKind) int {
+	switch kind {
+	case SpanKindServer:
+		return 2
+	case SpanKindClient:
+		return 3
+	default:
+		return 1
+	}
+}
+
+func otlpAttribute(attr Attribute) otlpKeyValue {
+	var value map[string]any
+	switch v := attr.Value.(type) {
+	case string:
+		value = map[string]any{"stringValue": v}
+	case bool:
+		value = map[string]any{"boolValue": v}
+	case int:
+		value = map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		value = map[string]any{"doubleValue": v}
+	default:
+		value = map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+	return otlpKeyValue{Key: attr.Key, Value: value}
+}
@@ -0,0 +1,100 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// collector is a stand-in OTLP/HTTP collector recording the requests it receives.
+type collector struct {
+	requests chan otlpRequest
+	headers  chan http.Header
+	status   int
+}
+
+func newCollector(t *testing.T, status int) (*collector, *httptest.Server) {
+	c := &collector{requests: make(chan otlpRequest, 10), headers: make(chan http.Header, 10), status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %v %v %v", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		c.requests <- req
+		c.headers <- r.Header
+		w.WriteHeader(c.status)
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func TestOTLPExporter(t *testing.T) {
+	t.Parallel()
+	c, srv := newCollector(t, http.StatusOK)
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer secret")
+	tracer := New(NewOTLPExporter(srv.URL+"/v1/traces", headers, "gomux1"))
+	start := time.Unix(1700000000, 0)
+	tracer.clock = func() time.Time { return start }
+
+	ctx, server := tracer.Start(context.Background(), "GET /v1/ping", SpanKindServer)
+	_, client := Start(ctx, "kubernetes get secret", SpanKindClient)
+	client.SetAttribute("retry", false)
+	client.SetAttribute("attempts", 2)
+	client.SetStatus(StatusError, "timeout")
+	client.End()
+	server.SetAttribute("http.route", "/v1/ping")
+	server.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-c.requests
+	if got := (<-c.headers).Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("wrong Authorization header: %q", got)
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("wrong request: %+v", req)
+	}
+	resource := req.ResourceSpans[0].Resource.Attributes
+	if len(resource) != 1 || resource[0].Key != "service.name" || resource[0].Value["stringValue"] != "gomux1" {
+		t.Errorf("wrong resource: %+v", resource)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got %+v", spans)
+	}
+	cs, ss := spans[0], spans[1]
+	if cs.TraceId != server.SpanContext().TraceID.String() || cs.ParentSpanId != ss.SpanId || ss.ParentSpanId != "" {
+		t.Errorf("wrong span IDs: %+v %+v", cs, ss)
+	}
+	if cs.Kind != 3 || ss.Kind != 2 {
+		t.Errorf("wrong kinds: %v %v", cs.Kind, ss.Kind)
+	}
+	if cs.Status != (otlpStatus{Code: 2, Message: "timeout"}) || ss.Status != (otlpStatus{}) {
+		t.Errorf("wrong statuses: %+v %+v", cs.Status, ss.Status)
+	}
+	if ss.StartTimeUnixNano != "1700000000000000000" {
+		t.Errorf("wrong start time: %v", ss.StartTimeUnixNano)
+	}
+	if len(cs.Attributes) != 2 || cs.Attributes[0].Value["boolValue"] != false || cs.Attributes[1].Value["intValue"] != "2" {
+		t.Errorf("wrong attributes: %+v", cs.Attributes)
+	}
+}
+
+func TestOTLPExporterError(t *testing.T) {
+	t.Parallel()
+	_, srv := newCollector(t, http.StatusServiceUnavailable)
+	exporter := NewOTLPExporter(srv.URL+"/v1/traces", nil, "gomux1")
+	err := exporter.ExportSpans(context.Background(), []SpanData{{Name: "span", SpanContext: SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}}})
+	if err == nil {
+		t.Error("want an error for a 503 from the collector")
+	}
+}
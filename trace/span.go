@@ -0,0 +1,308 @@
+package trace
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rakhbari/gomux1/logging"
+)
+
+// SpanKind is the role of a span in a trace.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer            // Serving a request
+	SpanKindClient            // Sending a request, e.g. to the Kubernetes API
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// StatusCode is the outcome of a span.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+func (c StatusCode) String() string {
+	switch c {
+	case StatusOK:
+		return "ok"
+	case StatusError:
+		return "error"
+	default:
+		return "unset"
+	}
+}
+
+// Attribute is a key/value pair describing a span. Values are strings, bools,
+// ints or float64s.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// SpanData is a snapshot of an ended span, as passed to exporters.
+type SpanData struct {
+	Name          string
+	SpanContext   SpanContext
+	ParentSpanID  SpanID // Zero for root spans
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span records an operation of a trace. All of its methods are safe to call on
+// a nil *Span, which is what Start returns outside of a trace.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SpanContext returns the propagated part of s.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetName renames s, e.g. once the route of a request is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+// SetAttribute sets the attribute key of s to value.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.data.Attributes {
+		if s.data.Attributes[i].Key == key {
+			s.data.Attributes[i].Value = value
+			return
+		}
+	}
+	s.data.Attributes = append(s.data.Attributes, Attribute{Key: key, Value: value})
+}
+
+// SetStatus sets the outcome of s. The message is only kept for StatusError.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = code
+	if code != StatusError {
+		message = ""
+	}
+	s.data.StatusMessage = message
+}
+
+// RecordError marks s as failed with err. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if err != nil {
+		s.SetStatus(StatusError, err.Error())
+	}
+}
+
+// End records the end of s and queues it for export if its trace is sampled.
+// Only the first call has any effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = s.tracer.clock()
+	data := s.data
+	data.Attributes = append([]Attribute(nil), s.data.Attributes...)
+	s.mu.Unlock()
+	if data.SpanContext.IsSampled() {
+		s.tracer.export(data)
+	}
+}
+
+type spanKey struct{}
+
+// NewContext returns a copy of ctx carrying span.
+func NewContext(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, or nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts a child of the span carried by ctx, with the same Tracer. If
+// ctx doesn't carry a span, e.g. outside of a request, it returns ctx and a nil Span.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, kind)
+}
+
+// Defaults of the export batching.
+const (
+	DefaultQueueSize     = 2048
+	DefaultBatchSize     = 512
+	DefaultFlushInterval = 5 * time.Second
+)
+
+// Tracer starts spans and exports the ended ones through its Exporter, in
+// batches sent from a background goroutine. Spans that don't fit in its queue
+// are dropped rather than slowing down requests.
+type Tracer struct {
+	exporter      Exporter
+	clock         func() time.Time
+	flushInterval time.Duration
+	logger        *slog.Logger
+
+	queue    chan SpanData
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a Tracer exporting spans through exporter. With a nil exporter
+// spans are still created and propagated but not exported.
+func New(exporter Exporter) *Tracer {
+	t := &Tracer{
+		exporter:      exporter,
+		clock:         time.Now,
+		flushInterval: DefaultFlushInterval,
+		logger:        slog.Default(),
+		queue:         make(chan SpanData, DefaultQueueSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if exporter == nil {
+		close(t.done)
+		return t
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a span as a child of the span or remote parent (see Extract)
+// carried by ctx, or as the root of a new sampled trace if there is neither.
+// It returns a copy of ctx carrying the new span, which must be ended with End.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{tracer: t, data: SpanData{Name: name, Kind: kind, Start: t.clock()}}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.data.SpanContext = parent.SpanContext()
+		span.data.ParentSpanID = parent.SpanContext().SpanID
+	} else if remote, ok := remoteFromContext(ctx); ok {
+		span.data.SpanContext = remote
+		span.data.ParentSpanID = remote.SpanID
+	} else {
+		span.data.SpanContext = SpanContext{TraceID: newTraceID(), Flags: FlagsSampled}
+	}
+	span.data.SpanContext.SpanID = newSpanID()
+	return NewContext(ctx, span), span
+}
+
+// Shutdown exports the queued spans and shuts the exporter down. Spans ended
+// afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) export(data SpanData) {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	select {
+	case t.queue <- data:
+	default:
+		t.logger.Warn("Span queue full, dropping span", "span", data.Name)
+	}
+}
+
+// run sends the queued spans to the exporter whenever a batch is full or
+// every flushInterval, until Shutdown is called.
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, DefaultBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.ExportSpans(context.Background(), batch); err != nil {
+			t.logger.Error("Exporting spans failed", "spans", len(batch), logging.Err(err))
+		}
+		batch = make([]SpanData, 0, DefaultBatchSize)
+	}
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) == DefaultBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			for {
+				select {
+				case data := <-t.queue:
+					batch = append(batch, data)
+					if len(batch) == DefaultBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package trace implements W3C Trace Context propagation and records spans,
+// which are exported in batches through an Exporter.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Propagation headers (https://www.w3.org/TR/trace-context/)
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// maxTracestateLength is the longest tracestate we'll propagate. Longer ones are dropped.
+const maxTracestateLength = 512
+
+// Flags are the trace-flags of a traceparent.
+type Flags byte
+
+// FlagsSampled marks a trace whose spans are recorded and exported.
+const FlagsSampled Flags = 0x01
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsValid reports whether id isn't all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether id isn't all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span that's propagated across process boundaries.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      Flags
+	TraceState string // The raw tracestate header, passed on as is
+}
+
+// IsValid reports whether sc has both a trace and a span ID.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the sampled flag of sc is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagsSampled != 0
+}
+
+// Traceparent returns sc as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{byte(sc.Flags)})
+}
+
+// ParseTraceparent parses a traceparent header value. Versions after 00 are
+// parsed as version 00, ignoring any fields they append, as the spec requires.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, false
+	}
+	version, ok := parseHex(s[:2])
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return sc, false
+	}
+	traceID, ok := parseHex(s[3:35])
+	if !ok {
+		return sc, false
+	}
+	spanID, ok := parseHex(s[36:52])
+	if !ok {
+		return sc, false
+	}
+	flags, ok := parseHex(s[53:55])
+	if !ok {
+		return sc, false
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = Flags(flags[0])
+	return sc, sc.IsValid()
+}
+
+// parseHex decodes lowercase hex only, since uppercase is invalid in a traceparent.
+func parseHex(s string) ([]byte, bool) {
+	if strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// Extract returns a copy of ctx carrying the remote parent span passed in the
+// traceparent and tracestate headers of h, if they're valid.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, ok := ParseTraceparent(strings.TrimSpace(h.Get(TraceparentHeader)))
+	if !ok {
+		return ctx
+	}
+	if state := strings.Join(h.Values(TracestateHeader), ","); len(state) <= maxTracestateLength {
+		sc.TraceState = state
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject sets the traceparent and tracestate headers of h to propagate the
+// span carried by ctx.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+// Transport returns an http.RoundTripper propagating the span carried by each
+// request's context to the server it's sent to.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if SpanFromContext(r.Context()) != nil {
+			r = r.Clone(r.Context())
+			Inject(r.Context(), r.Header)
+		}
+		return next.RoundTrip(r)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+type remoteKey struct{}
+
+// remoteFromContext returns the remote parent span carried by ctx, if any.
+func remoteFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return sc, ok
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,177 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in      string
+		ok      bool
+		sampled bool
+	}{
+		{testTraceparent, true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true, true}, // Future version with extra fields
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-600f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		sc, ok := ParseTraceparent(tt.in)
+		if ok != tt.ok {
+			t.Errorf("%q: got ok=%v want %v", tt.in, ok, tt.ok)
+			continue
+		}
+		if ok && sc.IsSampled() != tt.sampled {
+			t.Errorf("%q: got sampled=%v want %v", tt.in, sc.IsSampled(), tt.sampled)
+		}
+	}
+
+	sc, _ := ParseTraceparent(testTraceparent)
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("wrong IDs: %v %v", sc.TraceID, sc.SpanID)
+	}
+	if sc.Traceparent() != testTraceparent {
+		t.Errorf("wrong round trip: %v", sc.Traceparent())
+	}
+}
+
+func TestStartPropagation(t *testing.T) {
+	t.Parallel()
+	tracer := New(nil)
+
+	// A remote parent is continued
+	h := http.Header{}
+	h.Set(TraceparentHeader, testTraceparent)
+	h.Set(TracestateHeader, "vendor=value")
+	ctx, server := tracer.Start(Extract(context.Background(), h), "server", SpanKindServer)
+	remote, _ := ParseTraceparent(testTraceparent)
+	if got := server.SpanContext(); got.TraceID != remote.TraceID || got.SpanID == remote.SpanID || got.TraceState != "vendor=value" {
+		t.Errorf("wrong server span context: %+v", got)
+	}
+	if server.data.ParentSpanID != remote.SpanID {
+		t.Errorf("wrong parent: %v", server.data.ParentSpanID)
+	}
+
+	// Children started with the package level Start join the trace
+	_, client := Start(ctx, "client", SpanKindClient)
+	if client.SpanContext().TraceID != remote.TraceID || client.data.ParentSpanID != server.SpanContext().SpanID {
+		t.Errorf("wrong client span: %+v", client.data)
+	}
+
+	// Outgoing requests carry the child span
+	out := http.Header{}
+	Inject(NewContext(context.Background(), client), out)
+	if want := client.SpanContext().Traceparent(); out.Get(TraceparentHeader) != want {
+		t.Errorf("wrong traceparent: got %q want %q", out.Get(TraceparentHeader), want)
+	}
+	if out.Get(TracestateHeader) != "vendor=value" {
+		t.Errorf("wrong tracestate: %q", out.Get(TracestateHeader))
+	}
+
+	// New root spans start a sampled trace
+	_, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	if !root.SpanContext().IsValid() || !root.SpanContext().IsSampled() || root.data.ParentSpanID.IsValid() {
+		t.Errorf("wrong root span: %+v", root.data)
+	}
+
+	// Outside of a trace Start returns a nil Span, which is a no-op
+	_, none := Start(context.Background(), "none", SpanKindInternal)
+	if none != nil {
+		t.Errorf("want a nil span, got %+v", none)
+	}
+	none.SetAttribute("key", "value")
+	none.RecordError(errors.New("boom"))
+	none.End()
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+	var got string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(TraceparentHeader)
+	}))
+	defer upstream.Close()
+
+	ctx, span := New(nil).Start(context.Background(), "client", SpanKindClient)
+	req, _ := http.NewRequestWithContext(ctx, "GET", upstream.URL, nil)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got != span.SpanContext().Traceparent() {
+		t.Errorf("wrong traceparent: got %q want %q", got, span.SpanContext().Traceparent())
+	}
+	if req.Header.Get(TraceparentHeader) != "" {
+		t.Errorf("the caller's request was modified")
+	}
+}
+
+func TestJSONLinesExporter(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	tracer := New(NewJSONLinesExporter(&buf))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	tracer.clock = func() time.Time {
+		calls++
+		return now.Add(time.Duration(calls-1) * 1500 * time.Microsecond)
+	}
+
+	ctx, server := tracer.Start(context.Background(), "GET /v1/ping", SpanKindServer)
+	_, client := Start(ctx, "kubernetes get secret", SpanKindClient)
+	client.SetAttribute("k8s.namespace.name", "app1")
+	client.RecordError(errors.New("not found"))
+	client.End()
+	server.SetAttribute("http.response.status_code", 200)
+	server.End()
+	server.End() // Exported once
+
+	// Spans ended while unsampled aren't exported
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, unsampled := tracer.Start(Extract(context.Background(), h), "unsampled", SpanKindServer)
+	unsampled.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("want 2 spans, got %q", buf.String())
+	}
+	var spans [2]jsonSpan
+	for i, line := range lines {
+		if err := json.Unmarshal(line, &spans[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, s := spans[0], spans[1]
+	if c.Name != "kubernetes get secret" || c.Kind != "client" || c.Status != "error" || c.StatusMessage != "not found" ||
+		c.Attributes["k8s.namespace.name"] != "app1" || c.DurationMs != 1.5 {
+		t.Errorf("wrong client span: %+v", c)
+	}
+	if s.Name != "GET /v1/ping" || s.Kind != "server" || s.Status != "unset" || s.ParentSpanId != "" ||
+		s.Attributes["http.response.status_code"] != float64(200) || s.DurationMs != 4.5 {
+		t.Errorf("wrong server span: %+v", s)
+	}
+	if c.TraceId != s.TraceId || c.ParentSpanId != s.SpanId {
+		t.Errorf("client span isn't a child of the server span: %+v %+v", c, s)
+	}
+}
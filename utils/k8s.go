@@ -2,18 +2,25 @@ package utils
 
 import (
     "context"
-    "log"
+    "errors"
+    "strconv"
+    "time"
 
     corev1 "k8s.io/api/core/v1"
+    k8serrors "k8s.io/apimachinery/pkg/api/errors"
     metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
     "k8s.io/client-go/kubernetes"
     "k8s.io/client-go/tools/clientcmd"
+
+    "github.com/rakhbari/gomux1/logging"
+    "github.com/rakhbari/gomux1/metrics"
+    "github.com/rakhbari/gomux1/trace"
 )
 
-func GetSvcAcctToken(kubeConfigPath string, namespace string, svcAcctName string) (*string, error) {
-    secret, err := GetSvcAcctSecret(kubeConfigPath, namespace, svcAcctName+"-token")
+func GetSvcAcctToken(ctx context.Context, kubeConfigPath string, namespace string, svcAcctName string) (*string, error) {
+    secret, err := GetSvcAcctSecret(ctx, kubeConfigPath, namespace, svcAcctName+"-token")
     if err != nil {
-        log.Printf("Problem with GetSvcAcctSecret: %v", err)
+        logging.FromContext(ctx).Error("Getting service account secret failed", "secret", svcAcctName+"-token", logging.Err(err))
         return nil, err
     }
     token := string(secret.Data["token"])
@@ -21,24 +28,75 @@ func GetSvcAcctToken(kubeConfigPath string, namespace string, svcAcctName string
     return &token, err
 }
 
-func GetSvcAcctSecret(kubeConfigPath string, namespace string, secretName string) (*corev1.Secret, error) {
+func GetSvcAcctSecret(ctx context.Context, kubeConfigPath string, namespace string, secretName string) (secret *corev1.Secret, err error) {
+    ctx, span := trace.Start(ctx, "kubernetes get secret", trace.SpanKindClient)
+    defer func() {
+        span.RecordError(err)
+        span.End()
+    }()
+    span.SetAttribute("k8s.namespace.name", namespace)
+    span.SetAttribute("k8s.secret.name", secretName)
+
     config, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
     if err != nil {
-        log.Printf("Problem with BuildConfigFromFlags: %v", err)
+        logging.FromContext(ctx).Error("Loading kubeconfig failed", logging.KeyPath, kubeConfigPath, logging.Err(err))
         return nil, err
     }
+    // Propagate the trace to the Kubernetes API server
+    config.Wrap(trace.Transport)
 
     k8sClient, err := kubernetes.NewForConfig(config)
     if err != nil {
-        log.Printf("Problem with NewForConfig: %v", err)
+        logging.FromContext(ctx).Error("Creating Kubernetes client failed", logging.Err(err))
         return nil, err
     }
 
-    secret, err := k8sClient.CoreV1().Secrets(namespace).Get(
-        context.Background(),
+    start := time.Now()
+    secret, err = k8sClient.CoreV1().Secrets(namespace).Get(
+        ctx,
         secretName,
         metav1.GetOptions{},
     )
+    metrics.KubernetesRequestDuration.With("get_secret", kubernetesStatusCode(err)).Observe(time.Since(start).Seconds())
 
     return secret, err
 }
+
+// CheckKubernetesApi checks that the Kubernetes API server in the kubeconfig
+// at kubeConfigPath is reachable and accepts its credentials, by listing the
+// API groups, which unlike /version isn't served to anonymous clients.
+func CheckKubernetesApi(ctx context.Context, kubeConfigPath string) (err error) {
+    ctx, span := trace.Start(ctx, "kubernetes list api groups", trace.SpanKindClient)
+    defer func() {
+        span.RecordError(err)
+        span.End()
+    }()
+
+    config, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+    if err != nil {
+        return err
+    }
+    config.Wrap(trace.Transport)
+    k8sClient, err := kubernetes.NewForConfig(config)
+    if err != nil {
+        return err
+    }
+
+    start := time.Now()
+    err = k8sClient.Discovery().RESTClient().Get().AbsPath("/apis").Do(ctx).Error()
+    metrics.KubernetesRequestDuration.With("list_api_groups", kubernetesStatusCode(err)).Observe(time.Since(start).Seconds())
+    return err
+}
+
+// kubernetesStatusCode returns the HTTP status code of a Kubernetes API
+// request's result as a metric label, or "error" if the request didn't get a response.
+func kubernetesStatusCode(err error) string {
+    if err == nil {
+        return "200"
+    }
+    var status k8serrors.APIStatus
+    if errors.As(err, &status) {
+        return strconv.Itoa(int(status.Status().Code))
+    }
+    return "error"
+}
@@ -1,9 +1,14 @@
 package utils
 
 import (
+    "context"
+    "fmt"
     "log"
+    "net/http"
+    "net/http/httptest"
     "os"
     "path"
+    "path/filepath"
     "testing"
 )
 
@@ -13,9 +18,66 @@ func TestGetSvcAcctToken(t *testing.T) {
         panic(err)
     }
 
-    svcAcctToken, err := GetSvcAcctToken(path.Join(home, ".kube/config"), "app1", "user1")
+    svcAcctToken, err := GetSvcAcctToken(context.Background(), path.Join(home, ".kube/config"), "app1", "user1")
     if err != nil {
         log.Fatalf("Error from GetSvcAcctToken: %v", err)
     }
     log.Printf("svcAcctToken: %s", *svcAcctToken)
 }
+
+// writeKubeconfig writes a kubeconfig for the API server at url using token
+// and returns its path. Credentials are only sent over TLS, so url must be https.
+func writeKubeconfig(t *testing.T, url string, token string) string {
+    t.Helper()
+    kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+users:
+- name: test
+  user:
+    token: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`, url, token)
+    kubeConfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+    if err := os.WriteFile(kubeConfigPath, []byte(kubeconfig), 0600); err != nil {
+        t.Fatal(err)
+    }
+    return kubeConfigPath
+}
+
+func TestCheckKubernetesApi(t *testing.T) {
+    // A stand-in API server only serving /apis to clients with the right token
+    apiServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        if r.Header.Get("Authorization") != "Bearer valid" {
+            w.WriteHeader(http.StatusUnauthorized)
+            fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Unauthorized","code":401}`)
+            return
+        }
+        if r.URL.Path != "/apis" {
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+        fmt.Fprint(w, `{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`)
+    }))
+    defer apiServer.Close()
+
+    if err := CheckKubernetesApi(context.Background(), writeKubeconfig(t, apiServer.URL, "valid")); err != nil {
+        t.Errorf("want the check to pass, got %v", err)
+    }
+    if err := CheckKubernetesApi(context.Background(), writeKubeconfig(t, apiServer.URL, "invalid")); err == nil {
+        t.Error("want the check to fail with invalid credentials")
+    }
+    if err := CheckKubernetesApi(context.Background(), filepath.Join(t.TempDir(), "missing")); err == nil {
+        t.Error("want the check to fail without a kubeconfig")
+    }
+}
@@ -2,69 +2,38 @@ package utils
 
 import (
     "bytes"
-    "encoding/json"
-    "errors"
-    "log"
+    "crypto/tls"
+    "fmt"
+    "log/slog"
     "os"
 
-    "github.com/rakhbari/gomux1/config"
+    "github.com/rakhbari/gomux1/logging"
 )
 
-func ProcessError(err error) {
-    log.Printf("ERROR: %v", err)
-    os.Exit(1)
-}
-
-func GetTlsCertFile(cfg *config.Config) (tlsCertFile *string) {
-    if len(cfg.Server.TlsCaPaths) == 0 {
-        log.Println("---> No tlsCaPaths provided - Checking TlsCertPath ...")
-        if _, err := os.Stat(cfg.Server.TlsCertPath); errors.Is(err, os.ErrNotExist) {
-            log.Printf("!!!!!!> ERROR: File \"%+v\" doesn't exist! Returning ...\n", cfg.Server.TlsCertPath)
-            return nil
-        }
-        return &cfg.Server.TlsCertPath
-    }
-    caCertPaths := []string{cfg.Server.TlsCertPath}             // Initialize with value of the "leaf" cert
-    caCertPaths = append(caCertPaths, cfg.Server.TlsCaPaths...) // Append the tlsCaPaths to it
-    log.Printf("---> Processing caCertPaths: %+v\n", caCertPaths)
-
-    // Loop through caCertPaths and concat all their content into bundleData
-    var bundleData bytes.Buffer
-    for _, filePath := range caCertPaths {
-        log.Printf("------> Reading filePath: \"%+v\"\n", filePath)
-        if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
-            log.Printf("!!!!!!> ERROR: File \"%+v\" doesn't exist! Returning ...\n", filePath)
-            return nil
-        }
+// LoadTlsCertificate loads the key pair of the cert at certPath and the key at
+// keyPath. The cert file can hold the whole chain, or caPaths can list the
+// files of the intermediate and root CA certs, which are appended to the leaf
+// in memory, so nothing is written to disk.
+func LoadTlsCertificate(certPath string, keyPath string, caPaths []string) (tls.Certificate, error) {
+    var chain bytes.Buffer
+    for _, filePath := range append([]string{certPath}, caPaths...) {
+        slog.Debug("Reading cert file", logging.KeyPath, filePath)
         data, err := os.ReadFile(filePath)
         if err != nil {
-            log.Printf("!!!!!!> ERROR: %v", err)
-            return nil
+            return tls.Certificate{}, err
+        }
+        chain.Write(data)
+        if len(data) > 0 && data[len(data)-1] != '\n' {
+            chain.WriteByte('\n')
         }
-        bundleData.Write(data)
-    }
-
-    tlsCertBundle := cfg.Server.TempDir + "/tlsCertBundle"
-    err := os.WriteFile(tlsCertBundle, bundleData.Bytes(), 0644)
-    if err != nil {
-        log.Printf("!!!!!!> ERROR: %v", err)
-        return nil
     }
-
-    return &tlsCertBundle
-}
-
-func LoadVersion(version *Version) {
-    versionFile := "version.json"
-    f, err := os.Open(versionFile)
+    keyData, err := os.ReadFile(keyPath)
     if err != nil {
-        log.Printf("---> Version file %s not found. Returning ...", versionFile)
-        return
+        return tls.Certificate{}, err
     }
-    defer f.Close()
-
-    err = json.NewDecoder(f).Decode(version)
+    cert, err := tls.X509KeyPair(chain.Bytes(), keyData)
     if err != nil {
-        log.Printf("!!!> ERROR: Unable to parse version file %s. Error: %v", versionFile, err)
+        return tls.Certificate{}, fmt.Errorf("%s: %w", certPath, err)
     }
+    return cert, nil
 }
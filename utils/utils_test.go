@@ -0,0 +1,90 @@
+package utils
+
+import (
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/pem"
+    "math/big"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// writeCert writes a cert for cn, signed by parent (or self-signed if it's
+// nil), and its key to dir and returns them along with the cert's path.
+func writeCert(t *testing.T, dir string, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+    t.Helper()
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    template := &x509.Certificate{
+        SerialNumber:          big.NewInt(time.Now().UnixNano()),
+        Subject:               pkix.Name{CommonName: cn},
+        NotBefore:             time.Now().Add(-time.Hour),
+        NotAfter:              time.Now().Add(time.Hour),
+        IsCA:                  parent == nil,
+        BasicConstraintsValid: true,
+        KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+    }
+    if parent == nil {
+        parent, parentKey = template, key
+    }
+    der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    cert, err := x509.ParseCertificate(der)
+    if err != nil {
+        t.Fatal(err)
+    }
+    certPath := filepath.Join(dir, cn+".crt")
+    if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+        t.Fatal(err)
+    }
+    keyDer, err := x509.MarshalECPrivateKey(key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, cn+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+        t.Fatal(err)
+    }
+    return cert, key, certPath
+}
+
+func TestLoadTlsCertificate(t *testing.T) {
+    dir := t.TempDir()
+    root, rootKey, rootPath := writeCert(t, dir, "root", nil, nil)
+    intermediate, intermediateKey, intermediatePath := writeCert(t, dir, "intermediate", root, rootKey)
+    _, _, leafPath := writeCert(t, dir, "leaf", intermediate, intermediateKey)
+    keyPath := filepath.Join(dir, "leaf.key")
+
+    cert, err := LoadTlsCertificate(leafPath, keyPath, []string{intermediatePath, rootPath})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(cert.Certificate) != 3 {
+        t.Fatalf("want the leaf and 2 CA certs in the chain, got %d certs", len(cert.Certificate))
+    }
+    if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err != nil || leaf.Subject.CommonName != "leaf" {
+        t.Errorf("the leaf should come first: %v %v", leaf, err)
+    }
+    if entries, _ := os.ReadDir(dir); len(entries) != 6 {
+        t.Errorf("files were written while loading the cert: %v", entries)
+    }
+
+    // Without CA paths the cert file is used as is
+    if cert, err := LoadTlsCertificate(leafPath, keyPath, nil); err != nil || len(cert.Certificate) != 1 {
+        t.Errorf("LoadTlsCertificate() = %d certs, %v", len(cert.Certificate), err)
+    }
+    if _, err := LoadTlsCertificate(leafPath, keyPath, []string{filepath.Join(dir, "missing.crt")}); err == nil {
+        t.Error("want an error for a missing CA cert")
+    }
+    if _, err := LoadTlsCertificate(leafPath, filepath.Join(dir, "root.key"), nil); err == nil {
+        t.Error("want an error for a key that doesn't match the cert")
+    }
+}
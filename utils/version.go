@@ -1,7 +1,135 @@
 package utils
 
+import (
+    "encoding/json"
+    "log/slog"
+    "os"
+    "runtime"
+    "runtime/debug"
+    "time"
+
+    "github.com/rakhbari/gomux1/logging"
+)
+
+// Build info set at link time, e.g.:
+//
+//  go build -ldflags "-X github.com/rakhbari/gomux1/utils.gitSha=$(git rev-parse HEAD)"
+var (
+    buildTimestamp string
+    gitSha         string
+    gitBranch      string
+)
+
+// startTime approximates the process start time.
+var startTime = time.Now()
+
+// Version is the app's build info, served by /version.
 type Version struct {
-    Timestamp string `json:"timestamp"`
-    GitSha    string `json:"gitSha"`
-    GitBranch string `json:"gitBranch"`
+    Timestamp     string       `json:"timestamp"` // When it was built, or committed if that's all that's known
+    GitSha        string       `json:"gitSha"`
+    GitBranch     string       `json:"gitBranch"`
+    Dirty         bool         `json:"dirty"`                   // Built from a tree with uncommitted changes
+    Module        string       `json:"module,omitempty"`        // Main module path
+    ModuleVersion string       `json:"moduleVersion,omitempty"` // Set when built with go install module@version
+    GoVersion     string       `json:"goVersion,omitempty"`
+    Deps          []Dependency `json:"deps,omitempty"`
+    StartTime     time.Time    `json:"startTime"`
+    Uptime        string       `json:"uptime,omitempty"` // Set by /version when serving it
+}
+
+// Dependency is a module the app was built with.
+type Dependency struct {
+    Path    string `json:"path"`
+    Version string `json:"version"`
+    Replace string `json:"replace,omitempty"` // path@version of its replacement, if any
+}
+
+// IsZero reports whether v doesn't identify a build at all. The module path
+// and Go version are enough, as that's all a binary built with go run, or
+// go build outside of a git checkout, has.
+func (v Version) IsZero() bool {
+    return v.Timestamp == "" && v.GitSha == "" && v.GitBranch == "" && v.ModuleVersion == "" &&
+        v.Module == "" && v.GoVersion == ""
+}
+
+// ResolveVersion returns the app's build info, taking each field from the first
+// source that has it: the values set with -ldflags -X, the VCS info Go embeds
+// in binaries built in a git checkout, then the version.json file written by
+// `make build`.
+func ResolveVersion() Version {
+    version := Version{
+        Timestamp: buildTimestamp,
+        GitSha:    gitSha,
+        GitBranch: gitBranch,
+        GoVersion: runtime.Version(),
+        StartTime: startTime,
+    }
+    if info, ok := debug.ReadBuildInfo(); ok {
+        applyBuildInfo(&version, info)
+    }
+    if version.Timestamp == "" || version.GitSha == "" || version.GitBranch == "" {
+        file, err := readVersionFile("version.json")
+        if err != nil {
+            slog.Debug("Version file not loaded", logging.KeyPath, "version.json", logging.Err(err))
+        }
+        applyVersionFile(&version, file)
+    }
+    return version
+}
+
+// applyBuildInfo fills in the fields of version info has and version doesn't.
+func applyBuildInfo(version *Version, info *debug.BuildInfo) {
+    version.Module = info.Main.Path
+    if info.Main.Version != "(devel)" {
+        version.ModuleVersion = info.Main.Version
+    }
+    if info.GoVersion != "" {
+        version.GoVersion = info.GoVersion
+    }
+    for _, setting := range info.Settings {
+        switch setting.Key {
+        case "vcs.revision":
+            if version.GitSha == "" {
+                version.GitSha = setting.Value
+            }
+        case "vcs.time":
+            if version.Timestamp == "" {
+                version.Timestamp = setting.Value
+            }
+        case "vcs.modified":
+            version.Dirty = setting.Value == "true"
+        }
+    }
+    for _, dep := range info.Deps {
+        d := Dependency{Path: dep.Path, Version: dep.Version}
+        if dep.Replace != nil {
+            d.Replace = dep.Replace.Path + "@" + dep.Replace.Version
+        }
+        version.Deps = append(version.Deps, d)
+    }
+}
+
+// applyVersionFile fills in the fields of version file has and version doesn't.
+func applyVersionFile(version *Version, file Version) {
+    if version.Timestamp == "" {
+        version.Timestamp = file.Timestamp
+    }
+    if version.GitSha == "" {
+        version.GitSha = file.GitSha
+    }
+    if version.GitBranch == "" {
+        version.GitBranch = file.GitBranch
+    }
+}
+
+// readVersionFile reads the build info in the version.json file at path.
+func readVersionFile(path string) (Version, error) {
+    var version Version
+    f, err := os.Open(path)
+    if err != nil {
+        return version, err
+    }
+    defer f.Close()
+    err = json.NewDecoder(f).Decode(&version)
+    return version, err
 }
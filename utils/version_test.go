@@ -0,0 +1,71 @@
+package utils
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "runtime/debug"
+    "testing"
+)
+
+func TestApplyBuildInfo(t *testing.T) {
+    info := &debug.BuildInfo{
+        GoVersion: "go1.22.1",
+        Main:      debug.Module{Path: "github.com/rakhbari/gomux1", Version: "(devel)"},
+        Deps: []*debug.Module{
+            {Path: "github.com/gorilla/mux", Version: "v1.8.0"},
+            {Path: "golang.org/x/net", Version: "v0.3.0", Replace: &debug.Module{Path: "../net", Version: ""}},
+        },
+        Settings: []debug.BuildSetting{
+            {Key: "vcs.revision", Value: "0123abc"},
+            {Key: "vcs.time", Value: "2024-05-01T10:00:00Z"},
+            {Key: "vcs.modified", Value: "true"},
+        },
+    }
+
+    // -ldflags values win over the VCS info
+    version := Version{GitSha: "fromldflags"}
+    applyBuildInfo(&version, info)
+    want := Version{
+        Timestamp: "2024-05-01T10:00:00Z",
+        GitSha:    "fromldflags",
+        Dirty:     true,
+        Module:    "github.com/rakhbari/gomux1",
+        GoVersion: "go1.22.1",
+    }
+    if len(version.Deps) != 2 || version.Deps[1] != (Dependency{Path: "golang.org/x/net", Version: "v0.3.0", Replace: "../net@"}) {
+        t.Errorf("wrong deps: %+v", version.Deps)
+    }
+    version.Deps = nil
+    if !reflect.DeepEqual(version, want) {
+        t.Errorf("wrong version:\n got %+v\nwant %+v", version, want)
+    }
+    if (Version{Module: "github.com/rakhbari/gomux1", GoVersion: "go1.22.1"}).IsZero() {
+        t.Error("the module path and Go version of a go run binary should identify it")
+    }
+    if !(Version{}).IsZero() {
+        t.Error("a version without build info should be zero")
+    }
+}
+
+func TestApplyVersionFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "version.json")
+    data := `{"timestamp":"2024-05-01 10:00:00 UTC","gitSha":"fromfile","gitBranch":"main"}`
+    if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+        t.Fatal(err)
+    }
+    file, err := readVersionFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // The file only fills in what the other sources don't have
+    version := Version{GitSha: "0123abc", Timestamp: "2024-05-01T10:00:00Z"}
+    applyVersionFile(&version, file)
+    if version.GitSha != "0123abc" || version.Timestamp != "2024-05-01T10:00:00Z" || version.GitBranch != "main" {
+        t.Errorf("wrong version: %+v", version)
+    }
+    if _, err := readVersionFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+        t.Error("want an error for a missing file")
+    }
+}